@@ -0,0 +1,420 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+)
+
+// FromArchive extracts the tar, gzip compressed tar, or zip archive at path into
+// the new Dir. The format of the archive is detected from its content.
+//
+// The mode, modification time, and symlinks of each entry are preserved. When
+// running as root the ownership of each entry is also preserved. Hardlinks and
+// named pipes are supported in tar archives. Entries with a path outside of the
+// directory return an error.
+//
+// When used with a Manifest, FromArchive adds the entries in the archive to the
+// manifest, with the same properties they would have when extracted. The
+// modification times are not added to the manifest.
+func FromArchive(path string) PathOp {
+	return func(root Path) error {
+		entries, err := readArchive(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read archive %s", path)
+		}
+		return applyArchive(root, entries)
+	}
+}
+
+// FromTarReader extracts the tar archive read from r into the new Dir. The tar
+// archive may be gzip compressed. See FromArchive for details.
+func FromTarReader(r io.Reader) PathOp {
+	return func(root Path) error {
+		entries, err := readTar(r)
+		if err != nil {
+			return errors.Wrap(err, "failed to read tar archive")
+		}
+		return applyArchive(root, entries)
+	}
+}
+
+// ManifestFromArchive creates a Manifest from the tar, gzip compressed tar, or
+// zip archive at path, without extracting it. The manifest can be used with
+// Equal to compare an archive to a directory.
+//
+// The root directory of the manifest has the same properties as the root of a
+// manifest created by Expected.
+func ManifestFromArchive(t assert.TestingT, path string) Manifest {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	return Expected(t, FromArchive(path))
+}
+
+func applyArchive(root Path, entries []archiveEntry) error {
+	if m, ok := root.(*directoryPath); ok {
+		return addArchiveToManifest(m, entries)
+	}
+	return extractArchive(root.Path(), entries)
+}
+
+// archiveEntry is a file, directory, or link read from an archive.
+type archiveEntry struct {
+	// name is the cleaned, slash separated, path of the entry
+	name  string
+	mode  os.FileMode
+	uid   int
+	gid   int
+	mtime time.Time
+	// linkname is the target of a symlink
+	linkname string
+	// hardlink is the name of the entry which is the target of a hardlink
+	hardlink string
+	content  []byte
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+func readArchive(filename string) ([]archiveEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	magic := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if bytes.Equal(magic[:n], zipMagic) {
+		return readZip(filename)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return readTar(f)
+}
+
+// typeRegA is the type flag of a regular file in old tar archives. It is the
+// value of the deprecated tar.TypeRegA.
+const typeRegA = '\x00'
+
+func readTar(r io.Reader) ([]archiveEntry, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close() // nolint: errcheck
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	var entries []archiveEntry
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		switch {
+		case err == io.EOF:
+			return entries, nil
+		case err != nil:
+			return nil, err
+		}
+		name, err := cleanArchivePath(header.Name)
+		switch {
+		case err != nil:
+			return nil, err
+		case name == ".":
+			continue
+		}
+		entry := archiveEntry{
+			name:     name,
+			mode:     header.FileInfo().Mode(),
+			uid:      header.Uid,
+			gid:      header.Gid,
+			mtime:    header.ModTime,
+			linkname: header.Linkname,
+		}
+		switch header.Typeflag {
+		case tar.TypeLink:
+			if entry.hardlink, err = cleanArchivePath(header.Linkname); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg, typeRegA:
+			if entry.content, err = ioutil.ReadAll(reader); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func readZip(filename string) ([]archiveEntry, error) {
+	reader, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() // nolint: errcheck
+
+	entries := make([]archiveEntry, 0, len(reader.File))
+	for _, f := range reader.File {
+		name, err := cleanArchivePath(f.Name)
+		switch {
+		case err != nil:
+			return nil, err
+		case name == ".":
+			continue
+		}
+		entry := archiveEntry{name: name, mode: f.Mode(), mtime: f.Modified}
+		if entry.mode.IsRegular() || entry.mode&os.ModeSymlink != 0 {
+			content, err := readZipFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if entry.mode.IsRegular() {
+				entry.content = content
+			} else {
+				entry.linkname = string(content)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() // nolint: errcheck
+	return ioutil.ReadAll(rc)
+}
+
+// cleanArchivePath returns the cleaned slash separated path of an entry in an
+// archive, or an error if the path is outside of the root of the archive.
+func cleanArchivePath(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.Errorf("archive entry %s is outside of the directory", name)
+	}
+	return cleaned, nil
+}
+
+func extractArchive(dest string, entries []archiveEntry) error {
+	var dirs []archiveEntry
+	for _, entry := range entries {
+		target := filepath.Join(dest, filepath.FromSlash(entry.name))
+		if err := checkArchiveParents(dest, entry.name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := removeArchiveTarget(target, entry); err != nil {
+			return err
+		}
+
+		var err error
+		switch mode := entry.mode; {
+		case entry.hardlink != "":
+			if err := linkArchiveEntry(dest, entry, target); err != nil {
+				return err
+			}
+			continue
+		case mode.IsDir():
+			// properties are set after all the entries are extracted, so
+			// that a read-only directory can be extracted.
+			dirs = append(dirs, entry)
+			err = os.MkdirAll(target, 0755)
+		case mode&os.ModeSymlink != 0:
+			err = os.Symlink(entry.linkname, target)
+		case mode&os.ModeNamedPipe != 0:
+			err = mkfifo(target)
+		case mode.IsRegular():
+			err = ioutil.WriteFile(target, entry.content, defaultFileMode)
+		default:
+			err = errors.Errorf("archive entry %s has an unsupported file mode %s", entry.name, mode)
+		}
+		if err != nil {
+			return err
+		}
+		if !entry.mode.IsDir() {
+			if err := setArchiveProperties(entry, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target := filepath.Join(dest, filepath.FromSlash(dirs[i].name))
+		if err := setArchiveProperties(dirs[i], target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkArchiveParents returns an error if any of the parent directories of the
+// slash separated path name, relative to dest, is a symlink. An entry
+// extracted through a symlink, added by an earlier entry, could be written
+// outside of dest.
+func checkArchiveParents(dest, name string) error {
+	parts := strings.Split(name, "/")
+	current := dest
+	for i, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			return errors.Errorf("archive entry %s is outside of the directory: %s is a symlink",
+				name, path.Join(parts[:i+1]...))
+		}
+	}
+	return nil
+}
+
+// removeArchiveTarget removes an existing entry at target, added by an earlier
+// entry with the same name, so that the new entry replaces it instead of being
+// written through a symlink. A directory is only replaced by an entry which is
+// not a directory.
+func removeArchiveTarget(target string, entry archiveEntry) error {
+	info, err := os.Lstat(target)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case info.IsDir() && entry.hardlink == "" && entry.mode.IsDir():
+		return nil
+	}
+	return os.Remove(target)
+}
+
+// linkArchiveEntry creates a hardlink at target to the entry it links to. The
+// target of the hardlink must be a file which was already extracted, and not a
+// symlink.
+func linkArchiveEntry(dest string, entry archiveEntry, target string) error {
+	if err := checkArchiveParents(dest, entry.hardlink); err != nil {
+		return err
+	}
+	source := filepath.Join(dest, filepath.FromSlash(entry.hardlink))
+	info, err := os.Lstat(source)
+	switch {
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		return errors.Errorf("archive entry %s is a hardlink to symlink %s", entry.name, entry.hardlink)
+	}
+	return os.Link(source, target)
+}
+
+func setArchiveProperties(entry archiveEntry, target string) error {
+	if os.Geteuid() == 0 {
+		if err := os.Lchown(target, entry.uid, entry.gid); err != nil {
+			return err
+		}
+	}
+	if entry.mode&os.ModeSymlink != 0 {
+		return nil
+	}
+	if err := os.Chmod(target, entry.mode&preservedModeBits); err != nil {
+		return err
+	}
+	return os.Chtimes(target, entry.mtime, entry.mtime)
+}
+
+func addArchiveToManifest(root *directoryPath, entries []archiveEntry) error {
+	for _, entry := range entries {
+		if err := addArchiveEntry(root, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addArchiveEntry(root *directoryPath, entry archiveEntry) error {
+	dirName, name := path.Split(entry.name)
+	parent, err := manifestSubdir(root, dirName)
+	if err != nil {
+		return err
+	}
+
+	props := []PathOp{WithMode(entry.mode & preservedModeBits)}
+	if os.Geteuid() == 0 {
+		props = append(props, AsUser(entry.uid, entry.gid))
+	}
+
+	switch mode := entry.mode; {
+	case entry.hardlink != "":
+		if _, ok := lookupEntry(root.directory, entry.hardlink).(*symlink); ok {
+			return errors.Errorf("archive entry %s is a hardlink to symlink %s", entry.name, entry.hardlink)
+		}
+		target, err := filepath.Rel(
+			filepath.FromSlash(path.Clean(dirName)), filepath.FromSlash(entry.hardlink))
+		if err != nil {
+			return err
+		}
+		return parent.AddHardlink(name, target)
+	case mode.IsDir():
+		dir, err := manifestSubdir(root, entry.name)
+		if err != nil {
+			return err
+		}
+		return applyPathOps(dir, props)
+	case mode&os.ModeSymlink != 0:
+		return parent.AddSymlink(name, entry.linkname)
+	case mode&os.ModeNamedPipe != 0:
+		return parent.AddSpecial(name, kindFifo, props...)
+	case mode.IsRegular():
+		return parent.AddFile(name, append([]PathOp{WithBytes(entry.content)}, props...)...)
+	default:
+		return errors.Errorf("archive entry %s has an unsupported file mode %s", entry.name, mode)
+	}
+}
+
+// manifestSubdir returns the directory at the slash separated path dir relative
+// to root. Any missing directories are added with the same mode used by
+// extractArchive.
+func manifestSubdir(root *directoryPath, dir string) (*directoryPath, error) {
+	current := root
+	for _, name := range strings.Split(dir, "/") {
+		if name == "" {
+			continue
+		}
+		if _, ok := current.directory.items[name]; !ok {
+			if err := current.AddDirectory(name, WithMode(0755)); err != nil {
+				return nil, err
+			}
+		}
+		item, ok := current.directory.items[name].(*directory)
+		if !ok {
+			return nil, errors.Errorf("archive entry %s is not a directory", strings.TrimSuffix(dir, "/"))
+		}
+		current = &directoryPath{directory: item}
+	}
+	return current, nil
+}
@@ -0,0 +1,174 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/skip"
+)
+
+func TestFromArchiveWithTar(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+	stamp := time.Date(2011, 11, 11, 5, 55, 55, 0, time.UTC)
+	headers := []*tar.Header{
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0700, ModTime: stamp},
+		{Name: "bin/run.sh", Typeflag: tar.TypeReg, Mode: 0755, ModTime: stamp},
+		{Name: "config/", Typeflag: tar.TypeDir, Mode: 0500, ModTime: stamp},
+		{Name: "config/app.yaml", Typeflag: tar.TypeReg, Mode: 0600, ModTime: stamp},
+		{Name: "config/current", Typeflag: tar.TypeSymlink, Linkname: "app.yaml"},
+		{Name: "run", Typeflag: tar.TypeLink, Linkname: "bin/run.sh"},
+	}
+	contents := map[string]string{
+		"bin/run.sh":      "#!/bin/sh\n",
+		"config/app.yaml": "key: value\n",
+	}
+	archive := fs.NewFile(t, "archive", fs.WithBytes(tarGzip(t, headers, contents)))
+	defer archive.Remove()
+
+	dir := fs.NewDir(t, "test-from-archive", fs.FromArchive(archive.Path()))
+	defer dir.Remove()
+	defer os.Chmod(dir.Join("config"), 0700) // nolint: errcheck
+
+	expected := []fs.PathOp{
+		fs.WithDir("bin",
+			fs.WithFile("run.sh", "#!/bin/sh\n", fs.WithMode(0755), fs.WithTimestamps(stamp, stamp))),
+		fs.WithDir("config",
+			fs.WithMode(0500),
+			fs.WithTimestamps(stamp, stamp),
+			fs.WithFile("app.yaml", "key: value\n", fs.WithMode(0600)),
+			fs.WithSymlink("current", "app.yaml")),
+		fs.WithHardlink("run", "bin/run.sh"),
+	}
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t, expected...)))
+	assert.Assert(t, fs.Equal(dir.Path(), fs.ManifestFromArchive(t, archive.Path())))
+
+	t.Run("from reader", func(t *testing.T) {
+		dir := fs.NewDir(t, "test-from-archive",
+			fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, contents))))
+		defer dir.Remove()
+		defer os.Chmod(dir.Join("config"), 0700) // nolint: errcheck
+
+		assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t, expected...)))
+	})
+}
+
+func tarGzip(t *testing.T, headers []*tar.Header, contents map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	writer := tar.NewWriter(gz)
+	for _, header := range headers {
+		var content string
+		if header.Typeflag == tar.TypeReg || header.Typeflag == '\x00' {
+			content = contents[header.Name]
+		}
+		header.Size = int64(len(content))
+		assert.NilError(t, writer.WriteHeader(header))
+		_, err := writer.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, writer.Close())
+	assert.NilError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestFromArchiveWithZip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	for name, content := range map[string]string{"a/1": "one", "2": "two"} {
+		w, err := writer.Create(name)
+		assert.NilError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, writer.Close())
+
+	archive := fs.NewFile(t, "archive", fs.WithBytes(buf.Bytes()))
+	defer archive.Remove()
+
+	dir := fs.NewDir(t, "test-from-archive", fs.FromArchive(archive.Path()))
+	defer dir.Remove()
+
+	expected := fs.Expected(t,
+		fs.WithDir("a", fs.WithFile("1", "one", fs.MatchAnyFileMode)),
+		fs.WithFile("2", "two", fs.MatchAnyFileMode))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+	assert.Assert(t, fs.Equal(dir.Path(), fs.ManifestFromArchive(t, archive.Path())))
+}
+
+func TestFromArchiveOutsideOfDirectory(t *testing.T) {
+	headers := []*tar.Header{{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0644}}
+	dir := fs.NewDir(t, "test-from-archive")
+	defer dir.Remove()
+
+	err := fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, nil)))(dir)
+	assert.ErrorContains(t, err, "archive entry ../escape is outside of the directory")
+}
+
+func TestFromArchiveThroughSymlink(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "symlinks are not supported on windows")
+	outside := fs.NewDir(t, "test-from-archive-outside")
+	defer outside.Remove()
+
+	headers := []*tar.Header{
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside.Path()},
+		{Name: "link/passwd", Typeflag: tar.TypeReg, Mode: 0644},
+	}
+	dir := fs.NewDir(t, "test-from-archive")
+	defer dir.Remove()
+
+	err := fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, nil)))(dir)
+	assert.ErrorContains(t, err,
+		"archive entry link/passwd is outside of the directory: link is a symlink")
+	assert.Assert(t, fs.Equal(outside.Path(), fs.Expected(t)))
+
+	t.Run("replace symlink", func(t *testing.T) {
+		headers := []*tar.Header{
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside.Join("file")},
+			{Name: "link", Typeflag: tar.TypeReg, Mode: 0644},
+		}
+		dir := fs.NewDir(t, "test-from-archive",
+			fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, map[string]string{"link": "content"}))))
+		defer dir.Remove()
+
+		assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t,
+			fs.WithFile("link", "content", fs.WithMode(0644)))))
+		assert.Assert(t, fs.Equal(outside.Path(), fs.Expected(t)))
+	})
+}
+
+func TestFromArchiveHardlinkToSymlink(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "symlinks are not supported on windows")
+	headers := []*tar.Header{
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		{Name: "hard", Typeflag: tar.TypeLink, Linkname: "link"},
+	}
+	dir := fs.NewDir(t, "test-from-archive")
+	defer dir.Remove()
+
+	err := fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, nil)))(dir)
+	assert.ErrorContains(t, err, "archive entry hard is a hardlink to symlink link")
+
+	fakeT := &fakeT{}
+	fs.Expected(fakeT, fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, nil))))
+	assert.Assert(t, fakeT.failed)
+}
+
+func TestFromArchiveWithTarTypeRegA(t *testing.T) {
+	headers := []*tar.Header{
+		{Name: "file", Typeflag: '\x00', Mode: 0644, Format: tar.FormatGNU},
+	}
+	dir := fs.NewDir(t, "test-from-archive",
+		fs.FromTarReader(bytes.NewReader(tarGzip(t, headers, map[string]string{"file": "content"}))))
+	defer dir.Remove()
+
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t,
+		fs.WithFile("file", "content", fs.MatchAnyFileMode))))
+}
@@ -0,0 +1,166 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/internal/format"
+	"gotest.tools/v3/internal/goldenhook"
+)
+
+// matchContent is like MatchFileContent, except that the content of the file
+// must also match any other content expectations.
+func matchContent(f func([]byte) CompareResult) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(*filePath); ok {
+			m.file.contentMatchers = append(m.file.contentMatchers, f)
+		}
+		return nil
+	}
+}
+
+// ContentMatches is a PathOp that updates a Manifest so that the content of
+// the file at path must match the regular expression. re may be a string or a
+// *regexp.Regexp.
+//
+// Content matchers are checked in addition to the content set by WithFile or
+// WithContent. Use MatchAnyFileContent to only check the content matchers.
+func ContentMatches(re cmp.RegexOrPattern) PathOp {
+	return matchContent(func(content []byte) CompareResult {
+		var expr *regexp.Regexp
+		switch typed := re.(type) {
+		case *regexp.Regexp:
+			expr = typed
+		case string:
+			var err error
+			if expr, err = regexp.Compile(typed); err != nil {
+				return cmp.ResultFailure(err.Error())
+			}
+		default:
+			return cmp.ResultFailure(fmt.Sprintf("invalid type %T for regex pattern", re))
+		}
+		if expr.Match(content) {
+			return cmp.ResultSuccess
+		}
+		return cmp.ResultFailure(fmt.Sprintf("does not match regexp %q", expr.String()))
+	})
+}
+
+// ContentContains is a PathOp that updates a Manifest so that the content of
+// the file at path must contain substr.
+func ContentContains(substr string) PathOp {
+	return matchContent(func(content []byte) CompareResult {
+		if bytes.Contains(content, []byte(substr)) {
+			return cmp.ResultSuccess
+		}
+		return cmp.ResultFailure(fmt.Sprintf("does not contain %q", substr))
+	})
+}
+
+// ContentSize is a PathOp that updates a Manifest so that the file at path
+// must contain exactly size bytes.
+func ContentSize(size int) PathOp {
+	return matchContent(func(content []byte) CompareResult {
+		if len(content) == size {
+			return cmp.ResultSuccess
+		}
+		return cmp.ResultFailure(fmt.Sprintf("expected %d bytes got %d", size, len(content)))
+	})
+}
+
+// ContentJSONEqual is a PathOp that updates a Manifest so that the content of
+// the file at path must be a JSON document equal to expected. Whitespace and
+// the order of keys in objects are ignored.
+func ContentJSONEqual(expected string) PathOp {
+	return matchContent(func(content []byte) CompareResult {
+		var x, y interface{}
+		if err := json.Unmarshal([]byte(expected), &x); err != nil {
+			return cmp.ResultFailure(fmt.Sprintf("expected value is not valid JSON: %s", err))
+		}
+		if err := json.Unmarshal(content, &y); err != nil {
+			return cmp.ResultFailure(fmt.Sprintf("is not valid JSON: %s", err))
+		}
+		if reflect.DeepEqual(x, y) {
+			return cmp.ResultSuccess
+		}
+		return cmp.ResultFailure("JSON is not equal:\n" + diffJSON(x, y))
+	})
+}
+
+// diffJSON returns a diff of the indented JSON encoding of x and y. Keys in
+// objects are sorted by the encoding.
+func diffJSON(x, y interface{}) string {
+	xJSON, _ := json.MarshalIndent(x, "", "  ")
+	yJSON, _ := json.MarshalIndent(y, "", "  ")
+	return diffLines(string(xJSON), string(yJSON))
+}
+
+// diffLines returns an indented diff of x and y. A trailing newline in both x
+// and y is removed so that it does not add an empty line to the diff.
+func diffLines(x, y string) string {
+	if strings.HasSuffix(x, "\n") && strings.HasSuffix(y, "\n") {
+		x, y = strings.TrimSuffix(x, "\n"), strings.TrimSuffix(y, "\n")
+	}
+	diff := format.UnifiedDiff(format.DiffConfig{
+		A:    x,
+		B:    y,
+		From: "expected",
+		To:   "actual",
+	})
+	return indent(strings.TrimSuffix(diff, "\n"), "    ")
+}
+
+// ContentGolden is a PathOp that updates a Manifest so that the content of the
+// file at path must equal the content of the golden file filename. The golden
+// file is compared the same way as golden.Bytes, so a relative filename is
+// relative to ./testdata, variants are resolved by golden.Path, the file is
+// recorded as used by golden.TrackUsage, and running
+// `go test pkgname -test.update-golden` updates the golden file.
+//
+// When the golden package is not imported by the test binary the golden file
+// at filename is only compared, and never updated.
+func ContentGolden(filename string) PathOp {
+	return matchContent(func(content []byte) CompareResult {
+		if goldenhook.Compare == nil {
+			return compareGoldenFile(content, goldenPath(filename))
+		}
+		result, expected := goldenhook.Compare(content, filename)
+		if result == nil {
+			return goldenFailure(goldenhook.Path(filename), expected, content)
+		}
+		if r, ok := result.(CompareResult); ok {
+			return r
+		}
+		return cmp.ResultFailure("does not match golden file " + goldenhook.Path(filename))
+	})
+}
+
+func compareGoldenFile(content []byte, path string) CompareResult {
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cmp.ResultFailure(fmt.Sprintf("failed to read golden file: %s", err))
+	}
+	if bytes.Equal(expected, content) {
+		return cmp.ResultSuccess
+	}
+	return goldenFailure(path, expected, content)
+}
+
+func goldenFailure(path string, expected, actual []byte) CompareResult {
+	return cmp.ResultFailure(fmt.Sprintf("does not match golden file %s:\n%s",
+		path, diffLines(string(expected), string(actual))))
+}
+
+func goldenPath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join("testdata", filename)
+}
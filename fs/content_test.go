@@ -0,0 +1,183 @@
+package fs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/internal/goldenhook"
+)
+
+func TestContentMatchers(t *testing.T) {
+	dir := NewDir(t, t.Name(),
+		WithFile("config.json", `{"name": "app", "ports": [80, 443]}`),
+		WithFile("log", "started server on port 8080\n"),
+		WithFile("golden", "line one\nline two\n"))
+	defer dir.Remove()
+
+	t.Run("matching content", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("config.json", "", MatchAnyFileContent,
+				ContentJSONEqual(`{"ports":[80,443],"name":"app"}`)),
+			WithFile("log", "", MatchAnyFileContent,
+				ContentContains("server"),
+				ContentMatches(`port \d+`),
+				ContentMatches(regexp.MustCompile(`^started`)),
+				ContentSize(28)),
+			WithFile("golden", "", MatchAnyFileContent, ContentGolden("content.golden")))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("with glob", func(t *testing.T) {
+		manifest := Expected(t,
+			MatchFilesWithGlob("*", MatchAnyFileMode, ContentMatches(`\n$`)),
+			WithFile("config.json", "", MatchAnyFileContent,
+				ContentJSONEqual(`{"name": "app", "ports": [80, 443]}`)))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("content does not match", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("config.json", "", MatchAnyFileContent,
+				ContentJSONEqual(`{"name": "web", "ports": [80, 443]}`)),
+			WithFile("log", "", MatchAnyFileContent,
+				ContentContains("client"),
+				ContentMatches(`^port`),
+				ContentSize(3)),
+			WithFile("golden", "", MatchAnyFileContent, ContentGolden("content.golden")))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+
+		expected := fmtExpected(`directory %s does not match expected (2 changed):
+/
+├── ~ config.json
+│       content: JSON is not equal:
+│           --- expected
+│           +++ actual
+│           @@ -1,4 +1,4 @@
+│            {
+│           -  "name": "web",
+│           +  "name": "app",
+│              "ports": [
+│                80,
+└── ~ log
+        content: does not contain "client"
+        content: does not match regexp "^port"
+        content: expected 3 bytes got 28
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("config.json", "", MatchAnyFileContent, ContentJSONEqual(`{}`)),
+			WithFile("log", "", MatchAnyFileContent, ContentJSONEqual(`{}`)),
+			WithFile("golden", "", MatchAnyFileContent))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+
+		expected := fmtExpected(`directory %s does not match expected (2 changed):
+/
+├── ~ config.json
+│       content: JSON is not equal:
+│           --- expected
+│           +++ actual
+│           @@ -1 +1,7 @@
+│           -{}
+│           +{
+│           +  "name": "app",
+│           +  "ports": [
+│           +    80,
+│           +    443
+│           +  ]
+│           +}
+└── ~ log
+        content: is not valid JSON: invalid character 's' looking for beginning of value
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestContentGolden(t *testing.T) {
+	dir := NewDir(t, t.Name(), WithFile("out", "line one\nline 2\n"))
+	defer dir.Remove()
+
+	manifest := Expected(t, WithFile("out", "", MatchAnyFileContent, ContentGolden("content.golden")))
+	result := Equal(dir.Path(), manifest)()
+	assert.Assert(t, !result.Success())
+
+	expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ out
+        content: does not match golden file testdata/content.golden:
+            --- expected
+            +++ actual
+            @@ -1,2 +1,2 @@
+             line one
+            -line two
+            +line 2
+`, dir.Path())
+	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+
+	t.Run("without the golden package", func(t *testing.T) {
+		defer func(compare func([]byte, string) (cmp.Result, []byte)) {
+			goldenhook.Compare = compare
+		}(goldenhook.Compare)
+		goldenhook.Compare = nil
+
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestContentMatchersWithExactContent(t *testing.T) {
+	dir := NewDir(t, t.Name(), WithFile("log", "started server\n"))
+	defer dir.Remove()
+
+	manifest := Expected(t, WithFile("log", "started client\n", ContentContains("started")))
+	result := Equal(dir.Path(), manifest)()
+	assert.Assert(t, !result.Success())
+
+	expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ log
+        content:
+            --- expected
+            +++ actual
+            @@ -1,2 +1,2 @@
+            -started client
+            +started server
+             
+`, dir.Path())
+	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+
+	dir.AssertFile(t, "log", WithContent("started server\n"), ContentSize(15))
+	result = EqualFile(dir.Join("log"), WithContent("started"), ContentSize(15))()
+	assert.Assert(t, !result.Success())
+}
+
+func TestContentMatchersCountTowardsContentDiffLimit(t *testing.T) {
+	var dirOps, manifestOps []PathOp
+	for i := 0; i < maxContentDiffs+2; i++ {
+		name := fmt.Sprintf("file%02d.json", i)
+		dirOps = append(dirOps, WithFile(name, `{"a": 1}`))
+		manifestOps = append(manifestOps,
+			WithFile(name, "", MatchAnyFileContent, ContentJSONEqual(`{"a": 2}`)))
+	}
+	dir := NewDir(t, t.Name(), dirOps...)
+	defer dir.Remove()
+
+	result := Equal(dir.Path(), Expected(t, manifestOps...))()
+	assert.Assert(t, !result.Success())
+
+	msg := result.(cmpFailure).FailureMessage()
+	assert.Equal(t, strings.Count(msg, "--- expected"), maxContentDiffs)
+	assert.Equal(t, strings.Count(msg, "content: JSON is not equal: diff omitted"), 2)
+	assert.Assert(t, strings.HasSuffix(msg, `
+2 content diffs were omitted, set GOTESTTOOLS_FS_FULL_REPORT=true to show all of them
+`))
+}
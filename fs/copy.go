@@ -0,0 +1,423 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CopyOptions are the options used by FromDir to copy a directory tree. See
+// the CopyOp with the same name for a description of each option.
+type CopyOptions struct {
+	PreserveModes        bool
+	PreserveTimestamps   bool
+	PreserveHardlinks    bool
+	PreserveOwnership    bool
+	CopyExternalSymlinks bool
+	IncludeGlobs         []string
+	ExcludeGlobs         []string
+}
+
+// CopyOp is an option which changes how FromDir copies a directory tree.
+type CopyOp func(opts *CopyOptions)
+
+// PreserveModes is a CopyOp which copies the file mode of files, directories,
+// named pipes, and sockets, including the setuid, setgid, and sticky bits.
+// The mode of a directory is set after its contents are copied, so read-only
+// directories can be copied.
+func PreserveModes(opts *CopyOptions) {
+	opts.PreserveModes = true
+}
+
+// PreserveTimestamps is a CopyOp which copies the modification time of files,
+// directories, named pipes, and sockets. The access time is set to the same
+// value as the modification time.
+func PreserveTimestamps(opts *CopyOptions) {
+	opts.PreserveTimestamps = true
+}
+
+// PreserveHardlinks is a CopyOp which creates a hardlink for every file which
+// is a hardlink to a file that was already copied, instead of copying the
+// content again.
+func PreserveHardlinks(opts *CopyOptions) {
+	opts.PreserveHardlinks = true
+}
+
+// PreserveOwnership is a CopyOp which copies the uid and gid of files,
+// directories, named pipes, sockets, and symlinks. Ownership can only be
+// changed by root, so PreserveOwnership has no effect when the process is not
+// running as root, or on windows.
+func PreserveOwnership(opts *CopyOptions) {
+	opts.PreserveOwnership = true
+}
+
+// CopyExternalSymlinks is a CopyOp which copies the target of a symlink that
+// points outside of the source directory, instead of copying the symlink. If
+// the target is a directory the entire directory tree is copied. Copying fails
+// if the target does not exist.
+func CopyExternalSymlinks(opts *CopyOptions) {
+	opts.CopyExternalSymlinks = true
+}
+
+// IncludeGlobs is a CopyOp which limits the files copied to those which match
+// at least one of the glob patterns. Directories are always copied, even if
+// none of their files match. A pattern which contains a / is matched against
+// the slash separated path of the file relative to the source directory, any
+// other pattern is matched against the name of the file. See path.Match for the
+// pattern syntax.
+func IncludeGlobs(patterns ...string) CopyOp {
+	return func(opts *CopyOptions) {
+		opts.IncludeGlobs = append(opts.IncludeGlobs, patterns...)
+	}
+}
+
+// ExcludeGlobs is a CopyOp which skips any file or directory that matches one
+// of the glob patterns. Excluding a directory excludes all of its contents.
+// The patterns are matched the same way as IncludeGlobs. ExcludeGlobs takes
+// precedence over IncludeGlobs.
+func ExcludeGlobs(patterns ...string) CopyOp {
+	return func(opts *CopyOptions) {
+		opts.ExcludeGlobs = append(opts.ExcludeGlobs, patterns...)
+	}
+}
+
+const preservedModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// copier copies a directory tree to a directory, or to a manifest.
+type copier struct {
+	CopyOptions
+	// root is the absolute path to the source directory
+	root string
+	// dest is the destination directory
+	dest string
+	// copiedByID is the rel path of each copied file, by the device and inode of
+	// the source file. copied is used instead when the platform does not
+	// provide the device and inode.
+	copiedByID map[fileID]string
+	copied     []copiedFile
+	// copying is the set of real paths of the directories which are being
+	// copied, used to detect symlink cycles.
+	copying map[string]bool
+}
+
+type copiedFile struct {
+	info os.FileInfo
+	rel  string
+}
+
+// copyEntry is an entry in a source directory which will be copied.
+type copyEntry struct {
+	name string
+	// rel is the slash separated path relative to the source directory
+	rel string
+	// source is the path to copy, which is the target of a symlink when the
+	// symlink is followed by CopyExternalSymlinks
+	source string
+	info   os.FileInfo
+	// linkTo is the rel path of a file which was already copied, when the
+	// entry is a hardlink to that file and hardlinks are preserved
+	linkTo string
+}
+
+func newCopier(source, dest string, ops []CopyOp) (*copier, error) {
+	root, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+	c := &copier{
+		root:       root,
+		dest:       dest,
+		copiedByID: make(map[fileID]string),
+		copying:    make(map[string]bool),
+	}
+	for _, op := range ops {
+		op(&c.CopyOptions)
+	}
+	for _, patterns := range [][]string{c.IncludeGlobs, c.ExcludeGlobs} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid glob pattern %q", pattern)
+			}
+		}
+	}
+	return c, nil
+}
+
+// entries returns the entries in the directory dir which should be copied. rel
+// is the slash separated path of dir relative to the source directory.
+func (c *copier) entries(dir, rel string) ([]copyEntry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]copyEntry, 0, len(infos))
+	for _, info := range infos {
+		entry := copyEntry{
+			name:   info.Name(),
+			rel:    path.Join(rel, info.Name()),
+			source: filepath.Join(dir, info.Name()),
+			info:   info,
+		}
+		if c.CopyExternalSymlinks && info.Mode()&os.ModeSymlink != 0 {
+			if entry, err = c.followExternalSymlink(entry); err != nil {
+				return nil, err
+			}
+		}
+		if !c.included(entry) {
+			continue
+		}
+		if c.PreserveHardlinks && entry.info.Mode().IsRegular() {
+			entry.linkTo = c.hardlinkTarget(entry)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (c *copier) included(entry copyEntry) bool {
+	if matchAnyGlob(c.ExcludeGlobs, entry.rel) {
+		return false
+	}
+	if len(c.IncludeGlobs) == 0 || entry.info.IsDir() {
+		return true
+	}
+	return matchAnyGlob(c.IncludeGlobs, entry.rel)
+}
+
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// followExternalSymlink replaces a symlink which points outside of the source
+// directory with the target of the symlink.
+func (c *copier) followExternalSymlink(entry copyEntry) (copyEntry, error) {
+	target, err := os.Readlink(entry.source)
+	if err != nil {
+		return entry, err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(entry.source), target)
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return entry, err
+	}
+	if isWithinDir(c.root, target) {
+		return entry, nil
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return entry, errors.Wrapf(err, "failed to copy the target of symlink %s", entry.source)
+	}
+	if info.IsDir() {
+		real, err := filepath.EvalSymlinks(target)
+		if err != nil {
+			return entry, err
+		}
+		if c.copying[real] {
+			return entry, errors.Errorf(
+				"failed to copy symlink %s: the target %s is a parent directory", entry.source, target)
+		}
+	}
+	entry.source = target
+	entry.info = info
+	return entry, nil
+}
+
+func isWithinDir(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// enterDir records that the directory at path is being copied, and returns a
+// function which removes the record when the copy is done.
+func (c *copier) enterDir(path string) (func(), error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	c.copying[real] = true
+	return func() {
+		delete(c.copying, real)
+	}, nil
+}
+
+// hardlinkTarget returns the rel path of a file which was already copied if
+// entry is a hardlink to the same file. Otherwise the entry is recorded as a
+// copied file, and an empty string is returned.
+func (c *copier) hardlinkTarget(entry copyEntry) string {
+	if id, ok := newFileID(entry.info); ok {
+		if rel, ok := c.copiedByID[id]; ok {
+			return rel
+		}
+		c.copiedByID[id] = entry.rel
+		return ""
+	}
+	for _, copied := range c.copied {
+		if os.SameFile(copied.info, entry.info) {
+			return copied.rel
+		}
+	}
+	c.copied = append(c.copied, copiedFile{info: entry.info, rel: entry.rel})
+	return ""
+}
+
+func (c *copier) copyDirectory(source, dest, rel string) error {
+	done, err := c.enterDir(source)
+	if err != nil {
+		return err
+	}
+	defer done()
+	entries, err := c.entries(source, rel)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.copyEntry(entry, filepath.Join(dest, entry.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *copier) copyEntry(entry copyEntry, dest string) error {
+	mode := entry.info.Mode()
+	var err error
+	switch {
+	case entry.linkTo != "":
+		return os.Link(filepath.Join(c.dest, filepath.FromSlash(entry.linkTo)), dest)
+	case mode.IsDir():
+		if err = os.Mkdir(dest, 0755); err == nil {
+			err = c.copyDirectory(entry.source, dest, entry.rel)
+		}
+	case mode&os.ModeSymlink != 0:
+		err = copySymLink(entry.source, dest)
+	case !mode.IsRegular():
+		err = copySpecial(entry.source, dest, entry.info)
+	default:
+		err = copyFile(entry.source, dest)
+	}
+	if err != nil {
+		return err
+	}
+	return c.copyProperties(entry, dest)
+}
+
+func (c *copier) copyProperties(entry copyEntry, dest string) error {
+	info := entry.info
+	if c.PreserveOwnership && os.Geteuid() == 0 {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err := os.Lchown(dest, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	if c.PreserveModes {
+		if err := os.Chmod(dest, info.Mode()&preservedModeBits); err != nil {
+			return err
+		}
+	}
+	if c.PreserveTimestamps {
+		return os.Chtimes(dest, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+func copySymLink(source, dest string) error {
+	link, err := os.Readlink(source)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(link, dest)
+}
+
+func copyFile(source, dest string) error {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, content, 0644)
+}
+
+// manifestOps returns the PathOps which add the expected result of
+// copyDirectory to a manifest.
+func (c *copier) manifestOps(source, rel string) ([]PathOp, error) {
+	done, err := c.enterDir(source)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	entries, err := c.entries(source, rel)
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]PathOp, 0, len(entries))
+	for _, entry := range entries {
+		op, err := c.manifestOp(entry)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func (c *copier) manifestOp(entry copyEntry) (PathOp, error) {
+	mode := entry.info.Mode()
+	props := c.manifestPropertyOps(entry)
+	switch {
+	case entry.linkTo != "":
+		target, err := filepath.Rel(
+			filepath.FromSlash(path.Dir(entry.rel)),
+			filepath.FromSlash(entry.linkTo))
+		return WithHardlink(entry.name, target), err
+	case mode.IsDir():
+		ops, err := c.manifestOps(entry.source, entry.rel)
+		return WithDir(entry.name, append(ops, props...)...), err
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(entry.source)
+		return WithSymlink(entry.name, target), err
+	case mode&os.ModeNamedPipe != 0:
+		return WithFifo(entry.name, props...), nil
+	case mode&os.ModeSocket != 0:
+		return WithSocket(entry.name, props...), nil
+	case !mode.IsRegular():
+		return nil, errors.Errorf("can not copy %s: unsupported file mode %s", entry.source, mode)
+	default:
+		content, err := ioutil.ReadFile(entry.source)
+		return WithFile(entry.name, "", append([]PathOp{WithBytes(content)}, props...)...), err
+	}
+}
+
+func (c *copier) manifestPropertyOps(entry copyEntry) []PathOp {
+	info := entry.info
+	var ops []PathOp
+	if c.PreserveOwnership && os.Geteuid() == 0 {
+		if uid, gid, ok := fileOwner(info); ok {
+			ops = append(ops, AsUser(uid, gid))
+		}
+	}
+	if c.PreserveModes {
+		ops = append(ops, WithMode(info.Mode()&preservedModeBits))
+	}
+	if c.PreserveTimestamps {
+		ops = append(ops, WithTimestamps(info.ModTime(), info.ModTime()))
+	}
+	return ops
+}
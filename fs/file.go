@@ -10,8 +10,10 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/internal/cleanup"
+	"gotest.tools/v3/internal/tempdir"
 )
 
 // Path objects return their filesystem path. Path may be implemented by a
@@ -30,6 +32,45 @@ var (
 // File is a temporary file on the filesystem
 type File struct {
 	path string
+	temp *tempName
+}
+
+// tempName is the name of a temporary file or directory created by NewFile or
+// NewDir.
+type tempName struct {
+	// prefix is used to create a random name when name is empty
+	prefix string
+	name   string
+	// modified is true once a PathOp other than WithParent or WithName has
+	// been applied.
+	modified bool
+	// moved is true if the PathOp being applied called move
+	moved bool
+}
+
+// tempNameOf returns the tempName of a File or Dir created by NewFile or
+// NewDir, or nil for any other path.
+func tempNameOf(path Path) *tempName {
+	switch typed := path.(type) {
+	case *File:
+		return typed.temp
+	case *Dir:
+		return typed.temp
+	}
+	return nil
+}
+
+// errPlacement is returned by WithParent and WithName when the file or
+// directory has already been modified.
+var errPlacement = errors.New("WithParent and WithName must be applied before other PathOps")
+
+// path returns the path of the file in parent, or an empty string if the name
+// is random.
+func (n *tempName) path(parent string) string {
+	if n.name == "" {
+		return ""
+	}
+	return filepath.Join(parent, n.name)
 }
 
 type helperT interface {
@@ -39,16 +80,22 @@ type helperT interface {
 // NewFile creates a new file in a temporary directory using prefix as part of
 // the filename. The PathOps are applied to the before returning the File.
 //
+// The file is created in the directory set by the GOTESTTOOLS_FS_TEMPDIR env
+// var, or in the default directory for temporary files if it is not set. Use
+// WithParent and WithName to change the location and name of the file.
+//
 // When used with Go 1.14+ the file will be automatically removed when the test
 // ends, unless the TEST_NOCLEANUP env var is set to true.
 func NewFile(t assert.TestingT, prefix string, ops ...PathOp) *File {
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
-	tempfile, err := ioutil.TempFile("", cleanPrefix(prefix)+"-")
+	root, err := tempRoot()
+	assert.NilError(t, err)
+	tempfile, err := ioutil.TempFile(root, cleanPrefix(prefix)+"-")
 	assert.NilError(t, err)
 
-	file := &File{path: tempfile.Name()}
+	file := &File{path: tempfile.Name(), temp: &tempName{prefix: cleanPrefix(prefix)}}
 	cleanup.Cleanup(t, file.Remove)
 
 	assert.NilError(t, tempfile.Close())
@@ -56,6 +103,16 @@ func NewFile(t assert.TestingT, prefix string, ops ...PathOp) *File {
 	return file
 }
 
+// tempRoot returns the directory where temporary files are created. An empty
+// string is the default directory for temporary files.
+func tempRoot() (string, error) {
+	root := os.Getenv(tempdir.EnvTempDir)
+	if root == "" {
+		return "", nil
+	}
+	return root, os.MkdirAll(root, 0755)
+}
+
 func cleanPrefix(prefix string) string {
 	// windows requires both / and \ are replaced
 	if runtime.GOOS == "windows" {
@@ -75,23 +132,76 @@ func (f *File) Remove() {
 	os.Remove(f.path)
 }
 
+// move the file to parent, and rename it to name. An empty parent or name
+// keeps the current value.
+func (f *File) move(parent, name string) error {
+	if f.temp == nil {
+		return nil
+	}
+	f.temp.moved = true
+	info, err := os.Stat(f.path)
+	switch {
+	case err != nil:
+		return err
+	case f.temp.modified || info.Size() > 0:
+		return errPlacement
+	}
+
+	if parent == "" {
+		parent = filepath.Dir(f.path)
+	}
+	if name != "" {
+		f.temp.name = name
+	}
+	path := f.temp.path(parent)
+	if path == f.path {
+		return nil
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	var file *os.File
+	if path == "" {
+		file, err = ioutil.TempFile(parent, f.temp.prefix+"-")
+	} else {
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	previous := f.path
+	f.path = file.Name()
+	return os.Remove(previous)
+}
+
 // Dir is a temporary directory
 type Dir struct {
 	path string
+	temp *tempName
 }
 
 // NewDir returns a new temporary directory using prefix as part of the directory
 // name. The PathOps are applied before returning the Dir.
 //
+// The directory is created in the directory set by the GOTESTTOOLS_FS_TEMPDIR
+// env var, or in the default directory for temporary files if it is not set.
+// Use WithParent and WithName to change the location and name of the directory.
+//
 // When used with Go 1.14+ the directory will be automatically removed when the test
 // ends, unless the TEST_NOCLEANUP env var is set to true.
 func NewDir(t assert.TestingT, prefix string, ops ...PathOp) *Dir {
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
-	path, err := ioutil.TempDir("", cleanPrefix(prefix)+"-")
+	root, err := tempRoot()
+	assert.NilError(t, err)
+	path, err := ioutil.TempDir(root, cleanPrefix(prefix)+"-")
 	assert.NilError(t, err)
-	dir := &Dir{path: path}
+	dir := &Dir{path: path, temp: &tempName{prefix: cleanPrefix(prefix)}}
 	cleanup.Cleanup(t, dir.Remove)
 
 	assert.NilError(t, applyPathOps(dir, ops))
@@ -109,6 +219,48 @@ func (d *Dir) Remove() {
 	os.RemoveAll(d.path)
 }
 
+// move the directory to parent, and rename it to name. An empty parent or name
+// keeps the current value.
+func (d *Dir) move(parent, name string) error {
+	if d.temp == nil {
+		return nil
+	}
+	d.temp.moved = true
+	entries, err := ioutil.ReadDir(d.path)
+	switch {
+	case err != nil:
+		return err
+	case d.temp.modified || len(entries) > 0:
+		return errPlacement
+	}
+
+	if parent == "" {
+		parent = filepath.Dir(d.path)
+	}
+	if name != "" {
+		d.temp.name = name
+	}
+	path := d.temp.path(parent)
+	if path == d.path {
+		return nil
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	if path == "" {
+		path, err = ioutil.TempDir(parent, d.temp.prefix+"-")
+	} else {
+		err = os.Mkdir(path, 0700)
+	}
+	if err != nil {
+		return err
+	}
+	previous := d.path
+	d.path = path
+	return os.Remove(previous)
+}
+
 // Join returns a new path with this directory as the base of the path
 func (d *Dir) Join(parts ...string) string {
 	return filepath.Join(append([]string{d.Path()}, parts...)...)
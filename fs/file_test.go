@@ -2,9 +2,13 @@ package fs_test
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/env"
 	"gotest.tools/v3/fs"
 	"gotest.tools/v3/internal/source"
 	"gotest.tools/v3/skip"
@@ -92,3 +96,99 @@ func TestNewDir_IntegrationWithCleanup(t *testing.T) {
 		assert.ErrorType(t, err, os.IsNotExist)
 	})
 }
+
+func TestNewDirWithParentAndName(t *testing.T) {
+	parent := fs.NewDir(t, t.Name())
+	defer parent.Remove()
+
+	t.Run("with parent", func(t *testing.T) {
+		dir := fs.NewDir(t, "prefix", fs.WithParent(parent.Join("a b", "ünicode")),
+			fs.WithFile("file1", "content"))
+		defer dir.Remove()
+
+		assert.Equal(t, filepath.Dir(dir.Path()), parent.Join("a b", "ünicode"))
+		assert.Assert(t, strings.HasPrefix(filepath.Base(dir.Path()), "prefix-"))
+		assert.Equal(t, string(dir.ReadFile(t, "file1")), "content")
+	})
+
+	t.Run("with name", func(t *testing.T) {
+		dir := fs.NewDir(t, "prefix", fs.WithName("fixture"), fs.WithParent(parent.Path()))
+		defer dir.Remove()
+		assert.Equal(t, dir.Path(), parent.Join("fixture"))
+
+		file := fs.NewFile(t, "prefix", fs.WithParent(dir.Path()), fs.WithName("file.txt"),
+			fs.WithContent("content"))
+		defer file.Remove()
+		assert.Equal(t, file.Path(), dir.Join("file.txt"))
+		assert.Equal(t, string(file.ReadFile(t)), "content")
+
+		fakeT := &fakeT{}
+		fs.NewDir(fakeT, "prefix", fs.WithParent(parent.Path()), fs.WithName("fixture"))
+		assert.Assert(t, fakeT.failed)
+	})
+
+	t.Run("after other ops", func(t *testing.T) {
+		fakeT := &fakeT{}
+		fs.NewDir(fakeT, "prefix", fs.WithFile("file1", ""), fs.WithParent(parent.Path()))
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg,
+			"WithParent and WithName must be applied before other PathOps"))
+	})
+
+	t.Run("after ops which do not add entries", func(t *testing.T) {
+		ft := &fakeT{}
+		fs.NewFile(ft, "prefix", fs.WithMode(0600), fs.WithParent(parent.Path()))
+		assert.Assert(t, ft.failed)
+		assert.Assert(t, is.Contains(ft.msg,
+			"WithParent and WithName must be applied before other PathOps"))
+
+		ft = &fakeT{}
+		dir := fs.NewDir(ft, "prefix", fs.WithMode(0700))
+		defer dir.Remove()
+		fs.Apply(ft, dir, fs.WithName("fixture"))
+		assert.Assert(t, ft.failed)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		fakeT := &fakeT{}
+		fs.NewFile(fakeT, "prefix", fs.WithName("a/b"))
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg, `invalid name "a/b"`))
+	})
+
+	t.Run("subdirectories are not moved", func(t *testing.T) {
+		dir := fs.NewDir(t, "prefix", fs.WithDir("sub", fs.WithParent(parent.Path())))
+		defer dir.Remove()
+		assert.DeepEqual(t, dir.List(t), []string{"sub/"})
+	})
+}
+
+func TestNewDirWithTempDirEnv(t *testing.T) {
+	root := fs.NewDir(t, t.Name())
+	defer root.Remove()
+	defer env.Patch(t, "GOTESTTOOLS_FS_TEMPDIR", root.Join("tmp"))()
+
+	dir := fs.NewDir(t, "prefix")
+	defer dir.Remove()
+	assert.Equal(t, filepath.Dir(dir.Path()), root.Join("tmp"))
+
+	file := fs.NewFile(t, "prefix")
+	defer file.Remove()
+	assert.Equal(t, filepath.Dir(file.Path()), root.Join("tmp"))
+}
+
+func TestNewDirWithParent_IntegrationWithCleanup(t *testing.T) {
+	skip.If(t, source.GoVersionLessThan(1, 14))
+	parent := fs.NewDir(t, t.Name())
+	defer parent.Remove()
+
+	t.Run("cleanup in subtest", func(t *testing.T) {
+		fs.NewDir(t, t.Name(), fs.WithParent(parent.Path()), fs.WithName("sub"))
+		_, err := os.Stat(parent.Join("sub"))
+		assert.NilError(t, err)
+	})
+
+	t.Run("dir has been removed", func(t *testing.T) {
+		assert.DeepEqual(t, parent.List(t), []string(nil))
+	})
+}
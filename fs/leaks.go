@@ -0,0 +1,122 @@
+package fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/internal/cleanup"
+)
+
+// CheckTempLeaks records the entries in the directory used for temporary
+// files, os.TempDir(), and returns a function which fails the test if there
+// are new entries in the directory. Names of entries which match one of the
+// glob patterns in allow are not reported. See filepath.Match for the glob
+// syntax.
+//
+// When used with Go 1.14+ the check runs when the test ends, after the cleanup
+// of any File or Dir created after CheckTempLeaks, unless the TEST_NOCLEANUP
+// env var is set to true. With older versions of Go, defer the returned
+// function. The check only runs once.
+//
+// Any process may create temporary files, so CheckTempLeaks should not be used
+// by tests which run in parallel with other tests.
+func CheckTempLeaks(t assert.TestingT, allow ...string) func() {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	dir := os.TempDir()
+	before, err := readNames(dir)
+	assert.NilError(t, err)
+
+	var once sync.Once
+	check := func() {
+		once.Do(func() {
+			assert.Assert(t, noTempLeaks(dir, before, allow))
+		})
+	}
+	cleanup.Cleanup(t, check)
+	return check
+}
+
+func readNames(dir string) (map[string]bool, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		names[info.Name()] = true
+	}
+	return names, nil
+}
+
+func noTempLeaks(dir string, before map[string]bool, allow []string) cmp.Comparison {
+	return func() cmp.Result {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		patterns := make([]*entryPattern, 0, len(allow))
+		for _, glob := range allow {
+			patterns = append(patterns, newGlobPattern(glob, nil))
+		}
+
+		var failures []failure
+		for _, info := range infos {
+			if before[info.Name()] {
+				continue
+			}
+			allowed, err := matchesAny(patterns, info.Name())
+			if err != nil {
+				return cmp.ResultFromError(err)
+			}
+			if !allowed {
+				failures = append(failures, failure{
+					path:     filepath.Join(string(os.PathSeparator), info.Name()),
+					kind:     failureExtra,
+					problems: []problem{problem("unexpected " + entryType(info.Mode()))},
+				})
+			}
+		}
+		if len(failures) == 0 {
+			return cmp.ResultSuccess
+		}
+		msg := fmt.Sprintf("temporary directory %s has entries left behind by the test", dir)
+		return cmp.ResultFailure(formatReport(msg, failures))
+	}
+}
+
+func matchesAny(patterns []*entryPattern, name string) (bool, error) {
+	for _, pattern := range patterns {
+		if ok, err := pattern.matchName(name); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// entryType returns the same type as the Type method of the dirEntry which is
+// created for a file with mode.
+func entryType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeNamedPipe != 0:
+		return kindFifo
+	case mode&os.ModeSocket != 0:
+		return kindSocket
+	case mode&os.ModeCharDevice != 0:
+		return kindCharDevice
+	case mode&os.ModeDevice != 0:
+		return kindBlockDevice
+	default:
+		return "file"
+	}
+}
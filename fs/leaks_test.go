@@ -0,0 +1,77 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/env"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/internal/source"
+	"gotest.tools/v3/skip"
+)
+
+// patchTempDir sets the directory returned by os.TempDir so that the test is
+// not affected by other tests which create temporary files.
+func patchTempDir(t *testing.T) func() {
+	tmpdir := fs.NewDir(t, t.Name())
+	reset := env.PatchAll(t, map[string]string{
+		"TMPDIR": tmpdir.Path(),
+		"TMP":    tmpdir.Path(),
+		"TEMP":   tmpdir.Path(),
+	})
+	return func() {
+		reset()
+		tmpdir.Remove()
+	}
+}
+
+func TestCheckTempLeaks(t *testing.T) {
+	defer patchTempDir(t)()
+	existing := fs.NewFile(t, "existing")
+	defer existing.Remove()
+
+	t.Run("no leaks", func(t *testing.T) {
+		fakeT := &fakeT{}
+		check := fs.CheckTempLeaks(fakeT)
+		dir := fs.NewDir(t, "removed")
+		dir.Remove()
+		check()
+		assert.Assert(t, !fakeT.failed, fakeT.msg)
+	})
+
+	t.Run("leaked file", func(t *testing.T) {
+		fakeT := &fakeT{}
+		check := fs.CheckTempLeaks(fakeT, "allowed-*")
+		leaked, err := ioutil.TempFile("", "leaked-")
+		assert.NilError(t, err)
+		assert.NilError(t, leaked.Close())
+		defer os.Remove(leaked.Name())
+		allowed := fs.NewDir(t, "allowed")
+		defer allowed.Remove()
+
+		check()
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg, "has entries left behind by the test (1 unexpected):"))
+		assert.Assert(t, is.Contains(fakeT.msg, "+ "+filepath.Base(leaked.Name())+"\n"))
+		assert.Assert(t, !strings.Contains(fakeT.msg, "allowed"))
+
+		fakeT.failed = false
+		check()
+		assert.Assert(t, !fakeT.failed, "check should only run once")
+	})
+}
+
+func TestCheckTempLeaks_IntegrationWithCleanup(t *testing.T) {
+	skip.If(t, source.GoVersionLessThan(1, 14))
+	defer patchTempDir(t)()
+
+	t.Run("cleanup runs before the check", func(t *testing.T) {
+		fs.CheckTempLeaks(t)
+		fs.NewDir(t, t.Name(), fs.WithFile("file", "content"))
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
@@ -20,6 +21,23 @@ type resource struct {
 	mode os.FileMode
 	uid  uint32
 	gid  uint32
+	// mtime is set for resources read from the filesystem, and for expected
+	// resources when WithTimestamps is used.
+	mtime time.Time
+	// matchMtime is true when mtime is an expected value which must be compared.
+	matchMtime     bool
+	mtimeTolerance time.Duration
+	// info is only set for resources read from the filesystem. It is used to
+	// identify hardlinks.
+	info os.FileInfo
+	// xattrs is set for resources read from the filesystem, and for expected
+	// resources when WithXattr is used.
+	xattrs map[string]string
+	// matchXattrs is true when xattrs are expected values which must be
+	// compared.
+	matchXattrs bool
+	// tolerance changes how the properties of an expected resource are compared
+	tolerance tolerance
 }
 
 type file struct {
@@ -27,6 +45,8 @@ type file struct {
 	content             io.ReadCloser
 	ignoreCariageReturn bool
 	compareContentFunc  func(b []byte) CompareResult
+	// contentMatchers are checked in addition to the expected content
+	contentMatchers []func(b []byte) CompareResult
 }
 
 func (f *file) Type() string {
@@ -42,10 +62,42 @@ func (f *symlink) Type() string {
 	return "symlink"
 }
 
+// hardlink is an expected file which must be a hardlink to the file at target.
+// target is relative to the directory which contains the hardlink.
+type hardlink struct {
+	target string
+}
+
+// Type returns file because a hardlink can not be distinguished from the file
+// it links to.
+func (f *hardlink) Type() string {
+	return "file"
+}
+
+// special is a named pipe, socket, or device.
+type special struct {
+	resource
+	kind string
+}
+
+func (f *special) Type() string {
+	return f.kind
+}
+
+const (
+	kindFifo        = "fifo"
+	kindSocket      = "socket"
+	kindCharDevice  = "char device"
+	kindBlockDevice = "block device"
+)
+
 type directory struct {
 	resource
-	items         map[string]dirEntry
-	filepathGlobs map[string]*filePath
+	items    map[string]dirEntry
+	patterns []*entryPattern
+	// parent is only set for directories read from the filesystem. It is used
+	// to resolve the target of a hardlink.
+	parent *directory
 }
 
 func (f *directory) Type() string {
@@ -64,12 +116,15 @@ func ManifestFromDir(t assert.TestingT, path string) Manifest {
 		ht.Helper()
 	}
 
-	manifest, err := manifestFromDir(path)
+	manifest, err := manifestFromDir(path, false)
 	assert.NilError(t, err)
 	return manifest
 }
 
-func manifestFromDir(path string) (Manifest, error) {
+// manifestFromDir reads the directory at path. Extended attributes are only
+// read if withXattrs is true, because reading them requires extra system calls
+// for every file.
+func manifestFromDir(path string, withXattrs bool) (Manifest, error) {
 	info, err := os.Stat(path)
 	switch {
 	case err != nil:
@@ -78,11 +133,11 @@ func manifestFromDir(path string) (Manifest, error) {
 		return Manifest{}, errors.Errorf("path %s must be a directory", path)
 	}
 
-	directory, err := newDirectory(path, info)
+	directory, err := newDirectory(path, info, withXattrs)
 	return Manifest{root: directory}, err
 }
 
-func newDirectory(path string, info os.FileInfo) (*directory, error) {
+func newDirectory(path string, info os.FileInfo, withXattrs bool) (*directory, error) {
 	items := make(map[string]dirEntry)
 	children, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -90,29 +145,57 @@ func newDirectory(path string, info os.FileInfo) (*directory, error) {
 	}
 	for _, child := range children {
 		fullPath := filepath.Join(path, child.Name())
-		items[child.Name()], err = getTypedResource(fullPath, child)
+		items[child.Name()], err = getTypedResource(fullPath, child, withXattrs)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &directory{
-		resource:      newResourceFromInfo(info),
-		items:         items,
-		filepathGlobs: make(map[string]*filePath),
-	}, nil
+	r, err := newResourceFromPath(path, info, withXattrs)
+	dir := &directory{
+		resource: r,
+		items:    items,
+	}
+	for _, item := range items {
+		if child, ok := item.(*directory); ok {
+			child.parent = dir
+		}
+	}
+	return dir, err
 }
 
-func getTypedResource(path string, info os.FileInfo) (dirEntry, error) {
+func getTypedResource(path string, info os.FileInfo, withXattrs bool) (dirEntry, error) {
 	switch {
 	case info.IsDir():
-		return newDirectory(path, info)
+		return newDirectory(path, info, withXattrs)
 	case info.Mode()&os.ModeSymlink != 0:
 		return newSymlink(path, info)
-	// TODO: devices, pipes?
+	case info.Mode()&os.ModeNamedPipe != 0:
+		return newSpecial(path, info, kindFifo, withXattrs)
+	case info.Mode()&os.ModeSocket != 0:
+		return newSpecial(path, info, kindSocket, withXattrs)
+	case info.Mode()&os.ModeCharDevice != 0:
+		return newSpecial(path, info, kindCharDevice, withXattrs)
+	case info.Mode()&os.ModeDevice != 0:
+		return newSpecial(path, info, kindBlockDevice, withXattrs)
 	default:
-		return newFile(path, info)
+		return newFile(path, info, withXattrs)
+	}
+}
+
+func newResourceFromPath(path string, info os.FileInfo, withXattrs bool) (resource, error) {
+	r := newResourceFromInfo(info)
+	if !withXattrs {
+		return r, nil
 	}
+	xattrs, err := readXattrs(path)
+	r.xattrs = xattrs
+	return r, err
+}
+
+func newSpecial(path string, info os.FileInfo, kind string, withXattrs bool) (*special, error) {
+	r, err := newResourceFromPath(path, info, withXattrs)
+	return &special{resource: r, kind: kind}, err
 }
 
 func newSymlink(path string, info os.FileInfo) (*symlink, error) {
@@ -126,14 +209,18 @@ func newSymlink(path string, info os.FileInfo) (*symlink, error) {
 	}, err
 }
 
-func newFile(path string, info os.FileInfo) (*file, error) {
+func newFile(path string, info os.FileInfo, withXattrs bool) (*file, error) {
+	r, err := newResourceFromPath(path, info, withXattrs)
+	if err != nil {
+		return nil, err
+	}
 	// TODO: defer file opening to reduce number of open FDs?
 	readCloser, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	return &file{
-		resource: newResourceFromInfo(info),
+		resource: r,
 		content:  readCloser,
 	}, err
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -54,7 +55,6 @@ func TestManifestFromDir(t *testing.T) {
 							content:  readCloser("content k"),
 						},
 					},
-					filepathGlobs: map[string]*filePath{},
 				},
 				"f": &symlink{
 					resource: newResource(defaultSymlinkMode),
@@ -65,7 +65,6 @@ func TestManifestFromDir(t *testing.T) {
 					content:  readCloser("content x"),
 				},
 			},
-			filepathGlobs: map[string]*filePath{},
 		},
 	}
 	actual := ManifestFromDir(t, srcDir.Path())
@@ -76,7 +75,14 @@ func TestManifestFromDir(t *testing.T) {
 }
 
 var cmpManifest = cmp.Options{
-	cmp.AllowUnexported(Manifest{}, resource{}, file{}, symlink{}, directory{}),
+	cmp.AllowUnexported(Manifest{}, resource{}, file{}, symlink{}, directory{}, tolerance{}),
+	cmp.FilterPath(func(path cmp.Path) bool {
+		field, ok := path.Last().(cmp.StructField)
+		return ok && field.Name() == "parent"
+	}, cmp.Ignore()),
+	cmp.FilterPath(isResourceField("mtime"), cmp.Ignore()),
+	cmp.FilterPath(isResourceField("info"), cmp.Ignore()),
+	cmp.FilterPath(isResourceField("xattrs"), cmp.Ignore()),
 	cmp.Comparer(func(x, y io.ReadCloser) bool {
 		if x == nil || y == nil {
 			return x == y
@@ -94,6 +100,15 @@ var cmpManifest = cmp.Options{
 	}),
 }
 
+// isResourceField returns a path filter for fields of resource which are only
+// set when the resource is read from the filesystem.
+func isResourceField(name string) func(cmp.Path) bool {
+	return func(path cmp.Path) bool {
+		field, ok := path.Last().(cmp.StructField)
+		return ok && field.Name() == name && path.Index(-2).Type() == reflect.TypeOf(resource{})
+	}
+}
+
 func readCloser(s string) io.ReadCloser {
 	return ioutil.NopCloser(strings.NewReader(s))
 }
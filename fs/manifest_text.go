@@ -0,0 +1,516 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+)
+
+/*
+ManifestFromFile reads a Manifest from a file in the text manifest format. The
+format describes a directory tree with one entry per line, and uses indentation
+to nest entries in a directory. Blank lines, and lines starting with #, are
+ignored. The first entry must be the root directory, named ".".
+
+	# expected output of the generator
+	dir . mode=0700
+	  file config.yaml mode=0600 content="key: value\n"
+	  file data.bin content=file:data.bin
+	  file run.log content=any
+	  symlink current target=config.yaml
+	  hardlink copy.yaml target=config.yaml
+	  dir "with spaces" mode=any uid=0 gid=0
+	    glob *.go mode=any content=any
+	    extra
+
+Each line starts with the type of entry, followed by the name of the entry, and
+then the properties of the entry as key=value pairs. Names and values may be
+quoted using Go string literal syntax. The types of entries are:
+
+	dir      a directory, which may contain other entries
+	file     a regular file
+	symlink  a symlink, the target property is required
+	hardlink a hardlink to the file at target, see WithHardlink
+	fifo     a named pipe, see WithFifo
+	socket   a unix domain socket, see WithSocket
+	glob     files matching a glob pattern, see MatchFilesWithGlob
+	extra    allow any other files in the directory, see MatchExtraFiles
+
+The properties are:
+
+	mode     the file mode in octal, including the setuid (04000), setgid
+	         (02000), and sticky (01000) bits, or any to match any mode
+	uid      the user id, defaults to the current user
+	gid      the group id, defaults to the current group
+	content  the file content, as an inline string, file:<path> to read the
+	         content from a file relative to the manifest file, or any to
+	         match any content
+	target   the target of a symlink or hardlink
+
+If mode is not set the default mode is the same as the default used by
+Expected, WithDir, and WithFile.
+*/
+func ManifestFromFile(t assert.TestingT, filename string) Manifest {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	f, err := os.Open(filename)
+	assert.NilError(t, err)
+	defer f.Close() // nolint: errcheck
+
+	ops, err := parseManifest(f, filepath.Dir(filename))
+	assert.NilError(t, err, "failed to parse manifest %s", filename)
+	return Expected(t, ops...)
+}
+
+type manifestNode struct {
+	line     int
+	indent   int
+	kind     string
+	name     string
+	props    map[string]string
+	children []*manifestNode
+}
+
+func parseManifest(r io.Reader, baseDir string) ([]PathOp, error) {
+	root, err := parseManifestNodes(r)
+	if err != nil {
+		return nil, err
+	}
+	return nodeOps(root, baseDir)
+}
+
+// nolint: gocyclo
+func parseManifestNodes(r io.Reader) (*manifestNode, error) {
+	var root *manifestNode
+	var stack []*manifestNode
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, errors.Errorf("line %d: indentation must use spaces", lineNum)
+		}
+		node, err := parseManifestLine(trimmed)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", lineNum)
+		}
+		node.line = lineNum
+		node.indent = len(line) - len(trimmed)
+
+		if root == nil {
+			if node.kind != "dir" || node.name != "." || node.indent != 0 {
+				return nil, errors.Errorf("line %d: first entry must be the root directory: dir .", lineNum)
+			}
+			root = node
+			stack = []*manifestNode{root}
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= node.indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			return nil, errors.Errorf("line %d: entry must be indented below the root directory", lineNum)
+		}
+		parent := stack[len(stack)-1]
+		if parent.kind != "dir" {
+			return nil, errors.Errorf("line %d: %s %s can not contain other entries (line %d)",
+				lineNum, parent.kind, parent.name, parent.line)
+		}
+		parent.children = append(parent.children, node)
+		stack = append(stack, node)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, errors.New("manifest is empty")
+	}
+	return root, nil
+}
+
+func parseManifestLine(line string) (*manifestNode, error) {
+	tokens, err := tokenizeManifestLine(line)
+	if err != nil {
+		return nil, err
+	}
+	node := &manifestNode{kind: tokens[0], props: make(map[string]string)}
+	switch node.kind {
+	case "extra":
+	case "dir", "file", "symlink", "hardlink", "fifo", "socket", "glob":
+		if len(tokens) < 2 {
+			return nil, errors.Errorf("%s requires a name", node.kind)
+		}
+		node.name = tokens[1]
+		tokens = tokens[1:]
+	default:
+		return nil, errors.Errorf("unknown entry type %q", node.kind)
+	}
+
+	for _, token := range tokens[1:] {
+		parts := strings.SplitN(token, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid property %q, expected key=value", token)
+		}
+		value, err := unquoteIfQuoted(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for %s", parts[0])
+		}
+		node.props[parts[0]] = value
+	}
+	return node, nil
+}
+
+// tokenizeManifestLine splits line on spaces. Spaces in quoted strings, or in
+// a quoted value of a key=value pair, do not split the token.
+func tokenizeManifestLine(line string) ([]string, error) {
+	var tokens []string
+	current := new(strings.Builder)
+	inQuote, escaped := false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case r == ' ' && !inQuote:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quoted string")
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	for i, token := range tokens {
+		if !strings.HasPrefix(token, `"`) {
+			continue
+		}
+		unquoted, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid quoted string %s", token)
+		}
+		tokens[i] = unquoted
+	}
+	return tokens, nil
+}
+
+func unquoteIfQuoted(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		return strconv.Unquote(value)
+	}
+	return value, nil
+}
+
+const matchAny = "any"
+
+// nolint: gocyclo
+func nodeOps(node *manifestNode, baseDir string) ([]PathOp, error) {
+	var ops []PathOp
+	for _, key := range sortedProps(node.props) {
+		value := node.props[key]
+		switch {
+		case key == "mode" && value == matchAny:
+			ops = append(ops, MatchAnyFileMode)
+		case key == "mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || mode > 07777 {
+				return nil, errors.Errorf("line %d: invalid mode %q", node.line, value)
+			}
+			ops = append(ops, WithMode(fileModeFromOctal(uint32(mode))))
+		case key == "uid" || key == "gid":
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, errors.Errorf("line %d: invalid %s %q", node.line, key, value)
+			}
+			ops = append(ops, withID(key, uint32(id)))
+		case key == "content" && node.kind != "file" && node.kind != "glob":
+			return nil, errors.Errorf("line %d: content is only valid for files", node.line)
+		case key == "content" && value == matchAny:
+			ops = append(ops, MatchAnyFileContent)
+		case key == "content" && strings.HasPrefix(value, "file:"):
+			filename := strings.TrimPrefix(value, "file:")
+			if !filepath.IsAbs(filename) {
+				filename = filepath.Join(baseDir, filepath.FromSlash(filename))
+			}
+			content, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", node.line)
+			}
+			ops = append(ops, WithBytes(content))
+		case key == "content":
+			ops = append(ops, WithContent(value))
+		case key == "target" && (node.kind == "symlink" || node.kind == "hardlink"):
+		default:
+			return nil, errors.Errorf("line %d: unknown property %q for %s", node.line, key, node.kind)
+		}
+	}
+
+	for _, child := range node.children {
+		childOps, err := nodeOps(child, baseDir)
+		if err != nil {
+			return nil, err
+		}
+		switch child.kind {
+		case "dir":
+			ops = append(ops, WithDir(child.name, childOps...))
+		case "file":
+			ops = append(ops, WithFile(child.name, "", childOps...))
+		case "symlink":
+			target, ok := child.props["target"]
+			if !ok || len(child.props) > 1 {
+				return nil, errors.Errorf("line %d: symlink requires only a target", child.line)
+			}
+			ops = append(ops, WithSymlink(child.name, target))
+		case "hardlink":
+			target, ok := child.props["target"]
+			if !ok || len(child.props) > 1 {
+				return nil, errors.Errorf("line %d: hardlink requires only a target", child.line)
+			}
+			ops = append(ops, WithHardlink(child.name, target))
+		case "fifo":
+			ops = append(ops, WithFifo(child.name, childOps...))
+		case "socket":
+			ops = append(ops, WithSocket(child.name, childOps...))
+		case "glob":
+			ops = append(ops, MatchFilesWithGlob(child.name, childOps...))
+		case "extra":
+			ops = append(ops, MatchExtraFiles)
+		}
+	}
+	return ops, nil
+}
+
+func sortedProps(props map[string]string) []string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The setuid, setgid, and sticky bits in the octal notation used by chmod.
+const (
+	octalSetuid = 04000
+	octalSetgid = 02000
+	octalSticky = 01000
+)
+
+// fileModeFromOctal returns the os.FileMode for mode in the octal notation used
+// by chmod.
+func fileModeFromOctal(mode uint32) os.FileMode {
+	fileMode := os.FileMode(mode).Perm()
+	if mode&octalSetuid != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&octalSetgid != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&octalSticky != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode
+}
+
+// octalFromFileMode returns the permissions, and the setuid, setgid, and sticky
+// bits of mode in the octal notation used by chmod.
+func octalFromFileMode(mode os.FileMode) uint32 {
+	octal := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		octal |= octalSetuid
+	}
+	if mode&os.ModeSetgid != 0 {
+		octal |= octalSetgid
+	}
+	if mode&os.ModeSticky != 0 {
+		octal |= octalSticky
+	}
+	return octal
+}
+
+// withID sets either the uid or gid of the resource at path
+func withID(key string, id uint32) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(manifestResource); ok {
+			if key == "uid" {
+				m.SetUID(id)
+			} else {
+				m.SetGID(id)
+			}
+		}
+		return nil
+	}
+}
+
+// WriteManifest writes the Manifest to w using the text manifest format
+// described by ManifestFromFile. WriteManifest can be used with
+// ManifestFromDir to create a manifest file from an existing directory.
+//
+// The content of every file is read from the Manifest, so the Manifest can not
+// be used with Equal after it is written. Manifests which use MatchFileContent,
+// WithXattr, WithTimestamps, IgnoreOwnership, ModeMask or WithUmask can not be
+// written.
+func WriteManifest(m Manifest, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := writeManifestDir(bw, ".", m.root, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeManifestDir(w *bufio.Writer, name string, dir *directory, depth int) error {
+	props, err := resourceProps("dir", name, dir.resource)
+	if err != nil {
+		return err
+	}
+	writeManifestLine(w, depth, "dir", name, props)
+
+	for _, childName := range sortedKeys(dir.items) {
+		if childName == anyFile {
+			continue
+		}
+		var err error
+		switch child := dir.items[childName].(type) {
+		case *directory:
+			err = writeManifestDir(w, childName, child, depth+1)
+		case *file:
+			err = writeManifestFile(w, depth+1, "file", childName, child)
+		case *symlink:
+			writeManifestLine(w, depth+1, "symlink", childName,
+				[]string{"target=" + quoteIfNeeded(child.target)})
+		case *hardlink:
+			writeManifestLine(w, depth+1, "hardlink", childName,
+				[]string{"target=" + quoteIfNeeded(child.target)})
+		case *special:
+			if child.kind != kindFifo && child.kind != kindSocket {
+				return errors.Errorf("%s %s can not be written", child.kind, childName)
+			}
+			var props []string
+			props, err = resourceProps(child.kind, childName, child.resource)
+			if err == nil {
+				writeManifestLine(w, depth+1, child.kind, childName, props)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range dir.patterns {
+		expected, ok := pattern.expected.(*file)
+		if !ok || pattern.regexp != nil {
+			return errors.Errorf("%s can not be written", pattern)
+		}
+		if err := writeManifestFile(w, depth+1, "glob", pattern.pattern, expected); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := dir.items[anyFile]; ok {
+		writeManifestLine(w, depth+1, "extra", "", nil)
+	}
+	return nil
+}
+
+func writeManifestFile(w *bufio.Writer, depth int, kind string, name string, f *file) error {
+	props, err := resourceProps(kind, name, f.resource)
+	if err != nil {
+		return err
+	}
+	switch {
+	case f.compareContentFunc != nil:
+		return errors.Errorf("%s %s uses MatchFileContent which can not be written", kind, name)
+	case len(f.contentMatchers) > 0:
+		return errors.Errorf("%s %s uses a content matcher which can not be written", kind, name)
+	case f.content == anyFileContent:
+		props = append(props, "content="+matchAny)
+	case f.content != nil:
+		content, err := ioutil.ReadAll(f.content)
+		f.content.Close() // nolint: errcheck
+		if err != nil {
+			return errors.Wrapf(err, "failed to read content of %s", name)
+		}
+		props = append(props, "content="+strconv.Quote(string(content)))
+	}
+	writeManifestLine(w, depth, kind, name, props)
+	return nil
+}
+
+func resourceProps(kind string, name string, r resource) ([]string, error) {
+	if op := unwritableOp(r); op != "" {
+		return nil, errors.Errorf("%s %s uses %s which can not be written", kind, name, op)
+	}
+	var props []string
+	if r.mode == anyFileMode {
+		props = append(props, "mode="+matchAny)
+	} else {
+		props = append(props, fmt.Sprintf("mode=%#o", octalFromFileMode(r.mode)))
+	}
+	if r.uid != currentUID() {
+		props = append(props, fmt.Sprintf("uid=%d", r.uid))
+	}
+	if r.gid != currentGID() {
+		props = append(props, fmt.Sprintf("gid=%d", r.gid))
+	}
+	return props, nil
+}
+
+// unwritableOp returns the name of the PathOp which set an expectation on r
+// that can not be written in the text manifest format, or an empty string.
+func unwritableOp(r resource) string {
+	switch {
+	case r.matchXattrs:
+		return "WithXattr"
+	case r.matchMtime:
+		return "WithTimestamps"
+	case r.tolerance.ignoresOwnership():
+		return "IgnoreOwnership"
+	case r.tolerance.modeMask != nil:
+		return "ModeMask"
+	case r.tolerance.umask != nil:
+		return "WithUmask"
+	}
+	return ""
+}
+
+func writeManifestLine(w *bufio.Writer, depth int, kind string, name string, props []string) {
+	fields := []string{kind}
+	if name != "" {
+		fields = append(fields, quoteIfNeeded(name))
+	}
+	fields = append(fields, props...)
+	w.WriteString(strings.Repeat("  ", depth) + strings.Join(fields, " ") + "\n") // nolint: errcheck
+}
+
+func quoteIfNeeded(value string) string {
+	if value == "" || strings.HasPrefix(value, "#") || strings.ContainsAny(value, " \"\\") ||
+		strconv.Quote(value) != `"`+value+`"` {
+		return strconv.Quote(value)
+	}
+	return value
+}
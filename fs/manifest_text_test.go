@@ -0,0 +1,249 @@
+package fs_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/skip"
+)
+
+func TestManifestFromFile(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("one", "first\nline", fs.WithMode(0600)),
+		fs.WithFile("two", "from a file\n"),
+		fs.WithFile("with space", "anything"),
+		fs.WithSymlink("link", "one"),
+		fs.WithHardlink("hardlink", "two"),
+		fs.WithDir("sub",
+			fs.WithMode(0700),
+			fs.WithFile("a.log", "a"),
+			fs.WithFile("b.log", "b"),
+			fs.WithFile("other", "c")))
+	defer dir.Remove()
+
+	manifestDir := fs.NewDir(t, t.Name(),
+		fs.WithFile("content.txt", "from a file\n"),
+		fs.WithFile("expected.txt", fmt.Sprintf(`
+# the expected tree
+dir . mode=any
+
+  file one mode=0600 content="first\nline"
+  file two content=file:content.txt
+  file "with space" content=any
+  symlink link target=%q
+  hardlink hardlink target=two
+  dir sub mode=0700
+    glob *.log mode=any content=any
+    extra
+`, dir.Join("one"))))
+	defer manifestDir.Remove()
+
+	expected := fs.ManifestFromFile(t, manifestDir.Join("expected.txt"))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+}
+
+func TestManifestFromFile_Errors(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		manifest string
+		expected string
+	}{
+		{
+			name:     "missing root",
+			manifest: "file one\n",
+			expected: "line 1: first entry must be the root directory: dir .",
+		},
+		{
+			name:     "unknown property",
+			manifest: "dir .\n  file one size=3\n",
+			expected: `line 2: unknown property "size" for file`,
+		},
+		{
+			name:     "children of a file",
+			manifest: "dir .\n  file one\n    file two\n",
+			expected: "line 3: file one can not contain other entries (line 2)",
+		},
+		{
+			name:     "invalid mode",
+			manifest: "dir .\n  dir sub mode=0799\n",
+			expected: `line 2: invalid mode "0799"`,
+		},
+		{
+			name:     "mode out of range",
+			manifest: "dir .\n  dir sub mode=017777\n",
+			expected: `line 2: invalid mode "017777"`,
+		},
+		{
+			name:     "first invalid property in sorted order",
+			manifest: "dir .\n  file one uid=x mode=x gid=x\n",
+			expected: `line 2: invalid gid "x"`,
+		},
+		{
+			name:     "unterminated quote",
+			manifest: "dir .\n  file \"one\n",
+			expected: "line 2: unterminated quoted string",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			file := fs.NewFile(t, "manifest", fs.WithContent(tc.manifest))
+			defer file.Remove()
+
+			fakeT := &fakeT{}
+			fs.ManifestFromFile(fakeT, file.Path())
+			assert.Assert(t, fakeT.failed)
+			assert.Assert(t, is.Contains(fakeT.msg, tc.expected))
+		})
+	}
+}
+
+type fakeT struct {
+	failed bool
+	msg    string
+}
+
+func (t *fakeT) Log(args ...interface{}) {
+	t.msg += fmt.Sprint(args...)
+}
+
+func (t *fakeT) FailNow() {
+	t.failed = true
+}
+
+func (t *fakeT) Fail() {
+	t.failed = true
+}
+
+func TestWriteManifest(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithMode(0700),
+		fs.WithFile("b", "two\n", fs.WithMode(0600)),
+		fs.WithFile("a", "one"),
+		fs.WithFile("with space", "\x00\x01"),
+		fs.WithSymlink("link", "a"),
+		fs.WithDir("sub", fs.WithMode(0750),
+			fs.WithFile("c", "")))
+	defer dir.Remove()
+
+	buf := new(bytes.Buffer)
+	assert.NilError(t, fs.WriteManifest(fs.ManifestFromDir(t, dir.Path()), buf))
+
+	expected := fmt.Sprintf(`dir . mode=0700
+  file a mode=0644 content="one"
+  file b mode=0600 content="two\n"
+  symlink link target=%s
+  dir sub mode=0750
+    file c mode=0644 content=""
+  file "with space" mode=0644 content="\x00\x01"
+`, dir.Join("a"))
+	assert.Equal(t, buf.String(), expected)
+
+	t.Run("round trip", func(t *testing.T) {
+		file := fs.NewFile(t, "manifest", fs.WithBytes(buf.Bytes()))
+		defer file.Remove()
+		assert.Assert(t, fs.Equal(dir.Path(), fs.ManifestFromFile(t, file.Path())))
+	})
+}
+
+func TestWriteManifest_SpecialModeBits(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithMode(0700),
+		fs.WithFile("setuid", "", fs.WithMode(0755|os.ModeSetuid)),
+		fs.WithFile("setgid", "", fs.WithMode(0750|os.ModeSetgid)),
+		fs.WithDir("sticky", fs.WithMode(0777|os.ModeSticky)))
+	defer dir.Remove()
+
+	buf := new(bytes.Buffer)
+	assert.NilError(t, fs.WriteManifest(fs.ManifestFromDir(t, dir.Path()), buf))
+
+	expected := `dir . mode=0700
+  file setgid mode=02750 content=""
+  file setuid mode=04755 content=""
+  dir sticky mode=01777
+`
+	assert.Equal(t, buf.String(), expected)
+
+	t.Run("round trip", func(t *testing.T) {
+		file := fs.NewFile(t, "manifest", fs.WithBytes(buf.Bytes()))
+		defer file.Remove()
+		assert.Assert(t, fs.Equal(dir.Path(), fs.ManifestFromFile(t, file.Path())))
+	})
+}
+
+func TestWriteManifest_MatchOps(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+	manifest := fs.Expected(t,
+		fs.MatchAnyFileMode,
+		fs.WithFile("a", "", fs.MatchAnyFileContent, fs.MatchAnyFileMode),
+		fs.WithDir("sub",
+			fs.MatchFilesWithGlob("*.go", fs.MatchAnyFileMode),
+			fs.MatchExtraFiles))
+
+	buf := new(bytes.Buffer)
+	assert.NilError(t, fs.WriteManifest(manifest, buf))
+
+	expected := `dir . mode=any
+  file a mode=any content=any
+  dir sub mode=0755
+    glob *.go mode=any
+    extra
+`
+	assert.Equal(t, buf.String(), expected)
+}
+
+func TestWriteManifest_UnsupportedOps(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var testcases = []struct {
+		name     string
+		ops      []fs.PathOp
+		expected string
+	}{
+		{
+			name:     "xattr",
+			ops:      []fs.PathOp{fs.WithFile("a", "", fs.WithXattr("user.key", "value"))},
+			expected: "file a uses WithXattr which can not be written",
+		},
+		{
+			name:     "timestamps",
+			ops:      []fs.PathOp{fs.WithDir("sub", fs.WithTimestamps(mtime, mtime))},
+			expected: "dir sub uses WithTimestamps which can not be written",
+		},
+		{
+			name:     "ignore ownership",
+			ops:      []fs.PathOp{fs.IgnoreOwnership},
+			expected: "dir . uses IgnoreOwnership which can not be written",
+		},
+		{
+			name:     "mode mask",
+			ops:      []fs.PathOp{fs.WithFile("a", "", fs.ModeMask(0700))},
+			expected: "file a uses ModeMask which can not be written",
+		},
+		{
+			name:     "umask",
+			ops:      []fs.PathOp{fs.MatchFilesWithGlob("*.go", fs.WithUmask(022))},
+			expected: "glob *.go uses WithUmask which can not be written",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := fs.Expected(t, tc.ops...)
+			err := fs.WriteManifest(manifest, new(bytes.Buffer))
+			assert.Error(t, err, tc.expected)
+		})
+	}
+}
@@ -15,9 +15,11 @@ const (
 func newResourceFromInfo(info os.FileInfo) resource {
 	statT := info.Sys().(*syscall.Stat_t)
 	return resource{
-		mode: info.Mode(),
-		uid:  statT.Uid,
-		gid:  statT.Gid,
+		mode:  info.Mode(),
+		uid:   statT.Uid,
+		gid:   statT.Gid,
+		mtime: info.ModTime(),
+		info:  info,
 	}
 }
 
@@ -8,7 +8,7 @@ const (
 )
 
 func newResourceFromInfo(info os.FileInfo) resource {
-	return resource{mode: info.Mode()}
+	return resource{mode: info.Mode(), mtime: info.ModTime(), info: info}
 }
 
 func (p *filePath) SetMode(mode os.FileMode) {
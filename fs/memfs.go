@@ -0,0 +1,474 @@
+// +build go1.16
+
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// MemFS is an in-memory filesystem which implements io/fs.FS, io/fs.ReadDirFS,
+// io/fs.ReadFileFS, and io/fs.StatFS. Use NewMemFS to create a MemFS.
+//
+// A MemFS is read-only once it is created, and is safe for concurrent use.
+type MemFS struct {
+	root *memNode
+}
+
+var (
+	_ iofs.ReadDirFS  = &MemFS{}
+	_ iofs.ReadFileFS = &MemFS{}
+	_ iofs.StatFS     = &MemFS{}
+)
+
+// memNode is a file, directory, symlink, or special file in a MemFS
+type memNode struct {
+	name     string
+	mode     iofs.FileMode
+	modTime  time.Time
+	content  []byte
+	target   string
+	children map[string]*memNode
+}
+
+// NewMemFS creates an in-memory filesystem with the structure created by ops.
+// NewMemFS accepts the same PathOps as NewDir, and Expected. The PathOps are
+// applied the same way as they are applied to a Manifest, so the files and
+// directories have the same default modes as the ones created by Expected.
+// The ownership of files is not stored. The modification time of each file is
+// the zero time, unless it is set by WithTimestamps.
+//
+// WithHardlink, and the PathOps which only apply to a Manifest, such as
+// MatchExtraFiles, are not supported.
+func NewMemFS(t assert.TestingT, ops ...PathOp) *MemFS {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	manifest := Expected(t, ops...)
+	root, err := memNodeFromDirectory(".", manifest.root)
+	assert.NilError(t, err)
+	return &MemFS{root: root}
+}
+
+func memNodeFromDirectory(name string, dir *directory) (*memNode, error) {
+	node := newMemNode(name, dir.resource)
+	node.children = make(map[string]*memNode)
+	for _, key := range sortedKeys(dir.items) {
+		if key == anyFile {
+			return nil, errors.New("MatchExtraFiles is not supported by MemFS")
+		}
+		child, err := memNodeFromEntry(path.Base(key), dir.items[key])
+		if err != nil {
+			return nil, err
+		}
+		if err := node.insert(key, child); err != nil {
+			return nil, err
+		}
+	}
+	if len(dir.patterns) > 0 {
+		return nil, errors.Errorf("%s is not supported by MemFS", dir.patterns[0])
+	}
+	return node, nil
+}
+
+func memNodeFromEntry(name string, entry dirEntry) (*memNode, error) {
+	switch typed := entry.(type) {
+	case *directory:
+		return memNodeFromDirectory(name, typed)
+	case *file:
+		node := newMemNode(name, typed.resource)
+		if typed.content != nil && typed.content != anyFileContent {
+			content, err := ioutil.ReadAll(typed.content)
+			typed.content.Close() // nolint: errcheck
+			if err != nil {
+				return nil, err
+			}
+			node.content = content
+		}
+		return node, nil
+	case *symlink:
+		node := newMemNode(name, typed.resource)
+		node.target = typed.target
+		return node, nil
+	case *special:
+		return newMemNode(name, typed.resource), nil
+	default:
+		return nil, errors.Errorf("%s: WithHardlink is not supported by MemFS", name)
+	}
+}
+
+func newMemNode(name string, r resource) *memNode {
+	node := &memNode{name: name, mode: r.mode}
+	if r.matchMtime {
+		node.modTime = r.mtime
+	}
+	return node
+}
+
+// insert child at the slash separated path relative to node. The parent of the
+// path must already exist.
+func (n *memNode) insert(name string, child *memNode) error {
+	parent := n
+	dir, base := path.Split(name)
+	for _, part := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		next, ok := parent.children[part]
+		if !ok || !next.mode.IsDir() {
+			return errors.Errorf("%s: parent directory %s does not exist", name, dir)
+		}
+		parent = next
+	}
+	parent.children[base] = child
+	return nil
+}
+
+func (n *memNode) sortedChildren() []*memNode {
+	children := make([]*memNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+const maxSymlinks = 40
+
+// lookup returns the node at name. If follow is true, and the node at name is
+// a symlink, the node at the target of the symlink is returned. Symlinks are
+// always followed for the parent directories of name.
+func (m *MemFS) lookup(op, name string, follow bool) (*memNode, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	node, err := m.resolve(name, follow, 0)
+	if err != nil {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	return node, nil
+}
+
+func (m *MemFS) resolve(name string, follow bool, links int) (*memNode, error) {
+	if name == "." {
+		return m.root, nil
+	}
+	parts := strings.Split(name, "/")
+	node := m.root
+	for i, part := range parts {
+		if !node.mode.IsDir() {
+			return nil, iofs.ErrNotExist
+		}
+		next, ok := node.children[part]
+		if !ok {
+			return nil, iofs.ErrNotExist
+		}
+		last := i == len(parts)-1
+		if next.mode&iofs.ModeSymlink == 0 || (last && !follow) {
+			node = next
+			continue
+		}
+		if links >= maxSymlinks {
+			return nil, errors.New("too many levels of symbolic links")
+		}
+		target := next.target
+		if !path.IsAbs(target) {
+			target = path.Join(path.Join(parts[:i]...), target)
+		}
+		if !iofs.ValidPath(target) {
+			// symlinks which point outside of the MemFS can not be resolved
+			return nil, iofs.ErrNotExist
+		}
+		remaining := append([]string{target}, parts[i+1:]...)
+		return m.resolve(path.Join(remaining...), follow, links+1)
+	}
+	return node, nil
+}
+
+// Open opens the named file or directory. Symlinks are followed.
+func (m *MemFS) Open(name string) (iofs.File, error) {
+	node, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{node: node, Reader: bytes.NewReader(node.content)}, nil
+}
+
+// ReadDir reads the named directory and returns a list of directory entries
+// sorted by filename.
+func (m *MemFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	node, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	children := node.sortedChildren()
+	entries := make([]iofs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, memFileInfo{node: child})
+	}
+	return entries, nil
+}
+
+// ReadFile reads the named file and returns its contents.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	node, err := m.lookup("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte(nil), node.content...), nil
+}
+
+// Stat returns a FileInfo describing the named file. Symlinks are followed.
+func (m *MemFS) Stat(name string) (iofs.FileInfo, error) {
+	node, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return memFileInfo{node: node}, nil
+}
+
+// Lstat returns a FileInfo describing the named file. If the file is a
+// symlink the FileInfo describes the symlink.
+func (m *MemFS) Lstat(name string) (iofs.FileInfo, error) {
+	node, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return memFileInfo{node: node}, nil
+}
+
+// ReadLink returns the target of the named symlink.
+func (m *MemFS) ReadLink(name string) (string, error) {
+	node, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&iofs.ModeSymlink == 0 {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: iofs.ErrInvalid}
+	}
+	return node.target, nil
+}
+
+// memFile is an open file or directory in a MemFS
+type memFile struct {
+	*bytes.Reader
+	node       *memNode
+	dirEntries []iofs.DirEntry
+	dirOffset  int
+}
+
+func (f *memFile) Stat() (iofs.FileInfo, error) {
+	return memFileInfo{node: f.node}, nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if f.node.mode.IsDir() {
+		return 0, &iofs.PathError{Op: "read", Path: f.node.name, Err: errors.New("is a directory")}
+	}
+	return f.Reader.Read(b)
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// ReadDir implements io/fs.ReadDirFile
+func (f *memFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if !f.node.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: f.node.name, Err: errors.New("not a directory")}
+	}
+	if f.dirEntries == nil {
+		children := f.node.sortedChildren()
+		f.dirEntries = make([]iofs.DirEntry, 0, len(children))
+		for _, child := range children {
+			f.dirEntries = append(f.dirEntries, memFileInfo{node: child})
+		}
+	}
+
+	remaining := f.dirEntries[f.dirOffset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+	f.dirOffset += len(remaining)
+	return remaining, nil
+}
+
+// memFileInfo implements both io/fs.FileInfo and io/fs.DirEntry
+type memFileInfo struct {
+	node *memNode
+}
+
+func (i memFileInfo) Name() string {
+	return i.node.name
+}
+
+func (i memFileInfo) Size() int64 {
+	return int64(len(i.node.content))
+}
+
+func (i memFileInfo) Mode() iofs.FileMode {
+	return i.node.mode
+}
+
+func (i memFileInfo) ModTime() time.Time {
+	return i.node.modTime
+}
+
+func (i memFileInfo) IsDir() bool {
+	return i.node.mode.IsDir()
+}
+
+func (i memFileInfo) Sys() interface{} {
+	return nil
+}
+
+func (i memFileInfo) Type() iofs.FileMode {
+	return i.node.mode.Type()
+}
+
+func (i memFileInfo) Info() (iofs.FileInfo, error) {
+	return i, nil
+}
+
+// EqualFS compares the io/fs.FS to the expected structure described by a
+// manifest and returns success if they match. EqualFS is the same as Equal,
+// except that it compares an io/fs.FS instead of a directory.
+//
+// An io/fs.FS does not provide the ownership of files, so all files are
+// expected to be owned by the current user. Symlinks can only be compared if
+// the io/fs.FS has a ReadLink method, like MemFS. An io/fs.FS can not report
+// which files are links to the same file, so a manifest which uses WithHardlink
+// can not be compared.
+//
+// EqualFS is a cmp.Comparison which can be used with assert.Assert().
+func EqualFS(fsys iofs.FS, expected Manifest) cmp.Comparison {
+	return func() cmp.Result {
+		if name, ok := findHardlink(expected.root, ""); ok {
+			return cmp.ResultFailure(fmt.Sprintf(
+				"EqualFS can not compare hardlinks, the manifest has a hardlink at %s", name))
+		}
+		actual, err := manifestFromFS(fsys)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		failures := eqDirectory("/", expected.root, actual.root)
+		if len(failures) == 0 {
+			return cmp.ResultSuccess
+		}
+		return cmp.ResultFailure(formatReport("filesystem does not match expected", failures))
+	}
+}
+
+// findHardlink returns the path of the first hardlink in dir, in sorted order.
+func findHardlink(dir *directory, dirPath string) (string, bool) {
+	for _, name := range sortedKeys(dir.items) {
+		switch entry := dir.items[name].(type) {
+		case *hardlink:
+			return path.Join(dirPath, name), true
+		case *directory:
+			if found, ok := findHardlink(entry, path.Join(dirPath, name)); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
+
+// readLinkFS is implemented by an io/fs.FS which supports symlinks.
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+func manifestFromFS(fsys iofs.FS) (Manifest, error) {
+	info, err := iofs.Stat(fsys, ".")
+	if err != nil {
+		return Manifest{}, err
+	}
+	dir, err := newDirectoryFromFS(fsys, ".", info)
+	return Manifest{root: dir}, err
+}
+
+func newDirectoryFromFS(fsys iofs.FS, name string, info iofs.FileInfo) (*directory, error) {
+	children, err := iofs.ReadDir(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	dir := &directory{
+		resource: resourceFromFSInfo(info),
+		items:    make(map[string]dirEntry, len(children)),
+	}
+	for _, child := range children {
+		childName := path.Join(name, child.Name())
+		childInfo, err := child.Info()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := newEntryFromFS(fsys, childName, childInfo)
+		if err != nil {
+			return nil, err
+		}
+		if childDir, ok := entry.(*directory); ok {
+			childDir.parent = dir
+		}
+		dir.items[child.Name()] = entry
+	}
+	return dir, nil
+}
+
+func newEntryFromFS(fsys iofs.FS, name string, info iofs.FileInfo) (dirEntry, error) {
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		return newDirectoryFromFS(fsys, name, info)
+	case mode&iofs.ModeSymlink != 0:
+		linkFS, ok := fsys.(readLinkFS)
+		if !ok {
+			return nil, errors.Errorf("%s: symlinks are not supported by %T", name, fsys)
+		}
+		target, err := linkFS.ReadLink(name)
+		return &symlink{resource: resourceFromFSInfo(info), target: target}, err
+	case mode&iofs.ModeNamedPipe != 0:
+		return &special{resource: resourceFromFSInfo(info), kind: kindFifo}, nil
+	case mode&iofs.ModeSocket != 0:
+		return &special{resource: resourceFromFSInfo(info), kind: kindSocket}, nil
+	case mode&iofs.ModeCharDevice != 0:
+		return &special{resource: resourceFromFSInfo(info), kind: kindCharDevice}, nil
+	case mode&iofs.ModeDevice != 0:
+		return &special{resource: resourceFromFSInfo(info), kind: kindBlockDevice}, nil
+	default:
+		content, err := iofs.ReadFile(fsys, name)
+		return &file{
+			resource: resourceFromFSInfo(info),
+			content:  ioutil.NopCloser(bytes.NewReader(content)),
+		}, err
+	}
+}
+
+func resourceFromFSInfo(info iofs.FileInfo) resource {
+	r := newResource(info.Mode())
+	r.mtime = info.ModTime()
+	r.info = info
+	return r
+}
@@ -0,0 +1,119 @@
+// +build go1.16
+
+package fs_test
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	gtfs "gotest.tools/v3/fs"
+)
+
+func TestMemFS(t *testing.T) {
+	stamp := time.Date(2011, 11, 11, 5, 55, 55, 0, time.UTC)
+	memfs := gtfs.NewMemFS(t,
+		gtfs.WithFile("file1", "content1", gtfs.WithMode(0600)),
+		gtfs.WithDir("sub",
+			gtfs.WithFile("file2", "content2", gtfs.WithTimestamps(stamp, stamp)),
+			gtfs.WithDir("empty")),
+		gtfs.WithFile("sub/file3", "content3"),
+		gtfs.WithSymlink("link", "sub/file2"),
+		gtfs.WithSymlink("linkdir", "sub"))
+
+	assert.NilError(t, fstest.TestFS(memfs,
+		"file1", "sub/file2", "sub/file3", "sub/empty", "link"))
+
+	content, err := memfs.ReadFile("linkdir/file2")
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "content2")
+
+	info, err := memfs.Stat("link")
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode(), fs.FileMode(0644))
+	assert.Equal(t, info.ModTime(), stamp)
+
+	info, err = memfs.Lstat("link")
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode()&fs.ModeSymlink, fs.ModeSymlink)
+
+	target, err := memfs.ReadLink("link")
+	assert.NilError(t, err)
+	assert.Equal(t, target, "sub/file2")
+
+	_, err = memfs.Open("missing")
+	assert.Assert(t, os.IsNotExist(err))
+	_, err = memfs.Open("/file1")
+	assert.ErrorContains(t, err, "invalid argument")
+}
+
+func TestMemFS_UnsupportedOps(t *testing.T) {
+	fakeT := &fakeT{}
+	gtfs.NewMemFS(fakeT, gtfs.WithFile("file1", ""), gtfs.WithHardlink("link", "file1"))
+	assert.Assert(t, fakeT.failed)
+	assert.Assert(t, is.Contains(fakeT.msg, "link: WithHardlink is not supported by MemFS"))
+}
+
+func TestEqualFS(t *testing.T) {
+	memfs := gtfs.NewMemFS(t,
+		gtfs.WithFile("file1", "content1"),
+		gtfs.WithDir("sub", gtfs.WithFile("file2", "content2")),
+		gtfs.WithSymlink("link", "file1"))
+
+	t.Run("success", func(t *testing.T) {
+		expected := gtfs.Expected(t,
+			gtfs.WithFile("file1", "content1"),
+			gtfs.WithDir("sub", gtfs.WithFile("file2", "content2")),
+			gtfs.WithSymlink("link", "file1"))
+		assert.Assert(t, gtfs.EqualFS(memfs, expected))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		expected := gtfs.Expected(t,
+			gtfs.WithFile("file1", "other"),
+			gtfs.WithDir("sub", gtfs.WithFile("file3", "content3")),
+			gtfs.WithSymlink("link", "file1"))
+		result := gtfs.EqualFS(memfs, expected)()
+		assert.Assert(t, !result.Success())
+		expectedMsg := `filesystem does not match expected (1 missing, 1 unexpected, 1 changed):
+/
+├── sub
+│   ├── - file3
+│   │       expected file to exist
+│   └── + file2
+│           unexpected file
+└── ~ file1
+        content:
+            --- expected
+            +++ actual
+            @@ -1 +1 @@
+            -other
+            +content1
+`
+		assert.Equal(t, result.(failure).FailureMessage(), expectedMsg)
+	})
+
+	t.Run("with a directory", func(t *testing.T) {
+		dir := gtfs.NewDir(t, t.Name(), gtfs.WithFile("file1", "content1"))
+		defer dir.Remove()
+		expected := gtfs.Expected(t, gtfs.MatchAnyFileMode, gtfs.WithFile("file1", "content1"))
+		assert.Assert(t, gtfs.EqualFS(os.DirFS(dir.Path()), expected))
+	})
+
+	t.Run("with a hardlink", func(t *testing.T) {
+		expected := gtfs.Expected(t,
+			gtfs.WithFile("file1", "content1"),
+			gtfs.WithDir("sub",
+				gtfs.WithFile("file2", "content2"),
+				gtfs.WithHardlink("copy", "file2")),
+			gtfs.WithSymlink("link", "file1"))
+		result := gtfs.EqualFS(memfs, expected)()
+		assert.Assert(t, !result.Success())
+		assert.Equal(t, result.(failure).FailureMessage(),
+			"EqualFS can not compare hardlinks, the manifest has a hardlink at sub/copy")
+	})
+}
@@ -13,7 +13,10 @@ import (
 	"gotest.tools/v3/assert"
 )
 
-const defaultFileMode = 0644
+const (
+	defaultFileMode = 0644
+	defaultDirMode  = 0755
+)
 
 // PathOp is a function which accepts a Path and performs an operation on that
 // path. When called with real filesystem objects (File or Dir) a PathOp modifies
@@ -25,6 +28,8 @@ type manifestResource interface {
 	SetMode(mode os.FileMode)
 	SetUID(uid uint32)
 	SetGID(gid uint32)
+	SetMtime(mtime time.Time)
+	SetXattr(name, value string)
 }
 
 type manifestFile interface {
@@ -37,6 +42,8 @@ type manifestDirectory interface {
 	AddSymlink(path, target string) error
 	AddFile(path string, ops ...PathOp) error
 	AddDirectory(path string, ops ...PathOp) error
+	AddHardlink(path, target string) error
+	AddSpecial(path, kind string, ops ...PathOp) error
 }
 
 // WithContent writes content to a file at Path
@@ -133,28 +140,42 @@ func WithFiles(files map[string]string) PathOp {
 	}
 }
 
-// FromDir copies the directory tree from the source path into the new Dir
-func FromDir(source string) PathOp {
+// FromDir copies the directory tree from the source path into the new Dir.
+// CopyOp can be used to preserve the properties of the files, or to select
+// which files are copied. By default files are created with mode 0644,
+// directories with mode 0755, and symlinks are copied as symlinks.
+//
+// When used with a Manifest, FromDir adds the files, directories, and symlinks
+// from the source path to the manifest, with the same properties they would
+// have when copied to a Dir.
+func FromDir(source string, ops ...CopyOp) PathOp {
 	return func(path Path) error {
+		c, err := newCopier(source, path.Path(), ops)
+		if err != nil {
+			return err
+		}
 		if _, ok := path.(manifestDirectory); ok {
-			return errors.New("use manifest.FromDir")
+			pathOps, err := c.manifestOps(source, "")
+			if err != nil {
+				return err
+			}
+			return applyPathOps(path, pathOps)
 		}
-		return copyDirectory(source, path.Path())
+		return c.copyDirectory(source, path.Path(), "")
 	}
 }
 
 // WithDir creates a subdirectory in the directory at path. Additional PathOp
 // can be used to modify the subdirectory
 func WithDir(name string, ops ...PathOp) PathOp {
-	const defaultMode = 0755
 	return func(path Path) error {
 		if m, ok := path.(manifestDirectory); ok {
-			ops = append([]PathOp{WithMode(defaultMode)}, ops...)
+			ops = append([]PathOp{WithMode(defaultDirMode)}, ops...)
 			return m.AddDirectory(name, ops...)
 		}
 
 		fullpath := filepath.Join(path.Path(), filepath.FromSlash(name))
-		err := os.MkdirAll(fullpath, defaultMode)
+		err := os.MkdirAll(fullpath, defaultDirMode)
 		if err != nil {
 			return err
 		}
@@ -171,10 +192,18 @@ func Apply(t assert.TestingT, path Path, ops ...PathOp) {
 }
 
 func applyPathOps(path Path, ops []PathOp) error {
+	temp := tempNameOf(path)
 	for _, op := range ops {
+		if temp != nil {
+			temp.moved = false
+		}
 		if err := op(path); err != nil {
 			return err
 		}
+		// WithParent and WithName must be applied before any other op
+		if temp != nil && !temp.moved {
+			temp.modified = true
+		}
 	}
 	return nil
 }
@@ -190,51 +219,6 @@ func WithMode(mode os.FileMode) PathOp {
 	}
 }
 
-func copyDirectory(source, dest string) error {
-	entries, err := ioutil.ReadDir(source)
-	if err != nil {
-		return err
-	}
-	for _, entry := range entries {
-		sourcePath := filepath.Join(source, entry.Name())
-		destPath := filepath.Join(dest, entry.Name())
-		switch {
-		case entry.IsDir():
-			if err := os.Mkdir(destPath, 0755); err != nil {
-				return err
-			}
-			if err := copyDirectory(sourcePath, destPath); err != nil {
-				return err
-			}
-		case entry.Mode()&os.ModeSymlink != 0:
-			if err := copySymLink(sourcePath, destPath); err != nil {
-				return err
-			}
-		default:
-			if err := copyFile(sourcePath, destPath); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func copySymLink(source, dest string) error {
-	link, err := os.Readlink(source)
-	if err != nil {
-		return err
-	}
-	return os.Symlink(link, dest)
-}
-
-func copyFile(source, dest string) error {
-	content, err := ioutil.ReadFile(source)
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(dest, content, 0644)
-}
-
 // WithSymlink creates a symlink in the directory which links to target.
 // Target must be a path relative to the directory.
 //
@@ -252,12 +236,16 @@ func WithSymlink(path, target string) PathOp {
 // WithHardlink creates a link in the directory which links to target.
 // Target must be a path relative to the directory.
 //
+// When used with a Manifest, the file at path is expected to be a hardlink to
+// the file at target. The properties and content of the file are compared
+// using the expectations for target.
+//
 // Note: the argument order is the inverse of os.Link to be consistent with
 // the other functions in this package.
 func WithHardlink(path, target string) PathOp {
 	return func(root Path) error {
-		if _, ok := root.(manifestDirectory); ok {
-			return errors.New("WithHardlink not implemented for manifests")
+		if v, ok := root.(manifestDirectory); ok {
+			return v.AddHardlink(path, target)
 		}
 		return os.Link(filepath.Join(root.Path(), target), filepath.Join(root.Path(), path))
 	}
@@ -265,11 +253,101 @@ func WithHardlink(path, target string) PathOp {
 
 // WithTimestamps sets the access and modification times of the file system object
 // at path.
+//
+// When used with a Manifest, the modification time of the file or directory at
+// path is expected to equal mtime. The access time is not compared. Use
+// MatchTimestampsWithin to allow the modification time to differ by some amount.
 func WithTimestamps(atime, mtime time.Time) PathOp {
 	return func(root Path) error {
-		if _, ok := root.(manifestDirectory); ok {
-			return errors.New("WithTimestamp not implemented for manifests")
+		if m, ok := root.(manifestResource); ok {
+			m.SetMtime(mtime)
+			return nil
 		}
 		return os.Chtimes(root.Path(), atime, mtime)
 	}
 }
+
+// WithFifo creates a named pipe in the directory at path. Additional PathOp can
+// be used to modify the named pipe. Named pipes are not supported on windows.
+func WithFifo(name string, ops ...PathOp) PathOp {
+	return withSpecial(name, kindFifo, mkfifo, ops)
+}
+
+// WithSocket creates a unix domain socket in the directory at path. The socket
+// is closed after it is created, so connections to the socket will fail.
+// Additional PathOp can be used to modify the socket. Unix sockets are not
+// supported on windows.
+func WithSocket(name string, ops ...PathOp) PathOp {
+	return withSpecial(name, kindSocket, mksocket, ops)
+}
+
+func withSpecial(name, kind string, create func(path string) error, ops []PathOp) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(manifestDirectory); ok {
+			ops = append([]PathOp{WithMode(defaultFileMode)}, ops...)
+			return m.AddSpecial(name, kind, ops...)
+		}
+
+		fullpath := filepath.Join(path.Path(), filepath.FromSlash(name))
+		if err := create(fullpath); err != nil {
+			return err
+		}
+		return applyPathOps(&File{path: fullpath}, ops)
+	}
+}
+
+// WithXattr sets the extended attribute name to value on the file or directory
+// at path. Extended attributes are only supported on linux.
+//
+// When used with a Manifest, the file or directory at path is expected to have
+// the extended attribute. Extended attributes which are not expected are not
+// compared.
+func WithXattr(name, value string) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(manifestResource); ok {
+			m.SetXattr(name, value)
+			return nil
+		}
+		return setXattr(path.Path(), name, value)
+	}
+}
+
+// movable is implemented by the File and Dir created by NewFile and NewDir.
+type movable interface {
+	move(parent, name string) error
+}
+
+// WithParent is a PathOp that creates the File or Dir returned by NewFile or
+// NewDir in the directory parent, instead of the default directory for
+// temporary files. parent is created if it does not exist, and it is not
+// removed by Remove.
+//
+// WithParent must be applied before any other PathOp, otherwise it returns an
+// error. It has no effect on other paths.
+func WithParent(parent string) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(movable); ok {
+			return m.move(parent, "")
+		}
+		return nil
+	}
+}
+
+// WithName is a PathOp that sets the name of the File or Dir returned by
+// NewFile or NewDir to name, instead of a random name created from the prefix.
+// It is an error if a file with that name already exists, which may happen when
+// a previous test run used TEST_NOCLEANUP.
+//
+// WithName must be applied before any other PathOp, otherwise it returns an
+// error. It has no effect on other paths.
+func WithName(name string) PathOp {
+	return func(path Path) error {
+		if filepath.Base(name) != name || name == "." || name == ".." {
+			return errors.Errorf("invalid name %q, must not contain a path separator", name)
+		}
+		if m, ok := path.(movable); ok {
+			return m.move("", name)
+		}
+		return nil
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"time"
 
 	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/fs"
+	"gotest.tools/v3/skip"
 )
 
 func TestFromDir(t *testing.T) {
@@ -98,3 +100,132 @@ func TestWithReaderContent(t *testing.T) {
 	expected := fs.Expected(t, fs.WithFile("1", content))
 	assert.Assert(t, fs.Equal(dir.Path(), expected))
 }
+
+func TestFromDirManifest(t *testing.T) {
+	dir := fs.NewDir(t, "test-from-dir", fs.FromDir("testdata/copy-test-with-symlink"))
+	defer dir.Remove()
+
+	expected := fs.Expected(t, fs.FromDir("testdata/copy-test-with-symlink"))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+
+	t.Run("with overrides", func(t *testing.T) {
+		fs.Apply(t, dir, fs.WithFile("extra", "more"))
+		expected := fs.Expected(t,
+			fs.FromDir("testdata/copy-test-with-symlink"),
+			fs.WithFile("extra", "more"))
+		assert.Assert(t, fs.Equal(dir.Path(), expected))
+	})
+}
+
+func TestFromDirWithFifo(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "named pipes are not supported on windows")
+	source := fs.NewDir(t, "test-from-dir-source",
+		fs.WithFile("1", "1\n"),
+		fs.WithFifo("pipe"))
+	defer source.Remove()
+
+	dir := fs.NewDir(t, "test-from-dir", fs.FromDir(source.Path()))
+	defer dir.Remove()
+
+	expected := fs.Expected(t,
+		fs.WithFile("1", "1\n"),
+		fs.WithFifo("pipe"))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t, fs.FromDir(source.Path()))))
+}
+
+func TestFromDirWithPreserveOps(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+	stamp := time.Date(2011, 11, 11, 5, 55, 55, 0, time.UTC)
+	source := fs.NewDir(t, "test-from-dir-source",
+		fs.WithFile("run.sh", "#!/bin/sh\n", fs.WithMode(0755), fs.WithTimestamps(stamp, stamp)),
+		fs.WithDir("sub",
+			fs.WithFile("readonly", "data", fs.WithMode(0400)),
+			fs.WithHardlink("run.sh", "../run.sh"),
+			fs.WithMode(0750),
+			fs.WithTimestamps(stamp, stamp)))
+	defer source.Remove()
+
+	ops := []fs.CopyOp{fs.PreserveModes, fs.PreserveTimestamps, fs.PreserveHardlinks}
+	dir := fs.NewDir(t, "test-from-dir", fs.FromDir(source.Path(), ops...))
+	defer dir.Remove()
+
+	expected := fs.Expected(t,
+		fs.WithFile("run.sh", "#!/bin/sh\n", fs.WithMode(0755), fs.WithTimestamps(stamp, stamp)),
+		fs.WithDir("sub",
+			fs.WithFile("readonly", "data", fs.WithMode(0400)),
+			fs.WithHardlink("run.sh", "../run.sh"),
+			fs.WithMode(0750),
+			fs.WithTimestamps(stamp, stamp)))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t, fs.FromDir(source.Path(), ops...))))
+}
+
+func TestFromDirWithGlobs(t *testing.T) {
+	dir := fs.NewDir(t, "test-from-dir", fs.FromDir("testdata/copy-test",
+		fs.IncludeGlobs("1"),
+		fs.ExcludeGlobs("a/b")))
+	defer dir.Remove()
+
+	expected := fs.Expected(t,
+		fs.WithFile("1", "1\n"),
+		fs.WithDir("a",
+			fs.WithFile("1", "1\n")))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		dir := fs.NewDir(t, "test-from-dir")
+		defer dir.Remove()
+		err := fs.FromDir("testdata/copy-test", fs.ExcludeGlobs("[a"))(dir)
+		assert.ErrorContains(t, err, `invalid glob pattern "[a"`)
+	})
+}
+
+func TestFromDirWithCopyExternalSymlinks(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "symlinks are not supported on windows")
+	external := fs.NewDir(t, "test-external",
+		fs.WithFile("file", "external"),
+		fs.WithDir("dir", fs.WithFile("nested", "nested")))
+	defer external.Remove()
+
+	source := fs.NewDir(t, "test-from-dir-source",
+		fs.WithFile("file", "internal"),
+		fs.WithSymlink("internal", "file"),
+		fs.WithSymlink("relative", "../"+filepath.Base(external.Path())+"/file"))
+	defer source.Remove()
+	assert.NilError(t, os.Symlink(external.Join("dir"), source.Join("dir")))
+
+	dir := fs.NewDir(t, "test-from-dir", fs.FromDir(source.Path(), fs.CopyExternalSymlinks))
+	defer dir.Remove()
+
+	expected := fs.Expected(t,
+		fs.WithFile("file", "internal"),
+		fs.WithSymlink("internal", source.Join("file")),
+		fs.WithFile("relative", "external"),
+		fs.WithDir("dir", fs.WithFile("nested", "nested")))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+}
+
+func TestFromDirWithCopyExternalSymlinksCycle(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "symlinks are not supported on windows")
+	external := fs.NewDir(t, "test-external", fs.WithFile("file", "external"))
+	defer external.Remove()
+	assert.NilError(t, os.Symlink(external.Path(), external.Join("loop")))
+
+	source := fs.NewDir(t, "test-from-dir-source")
+	defer source.Remove()
+	assert.NilError(t, os.Symlink(external.Path(), source.Join("external")))
+
+	dir := fs.NewDir(t, "test-from-dir")
+	defer dir.Remove()
+
+	err := fs.FromDir(source.Path(), fs.CopyExternalSymlinks)(dir)
+	assert.ErrorContains(t, err, "failed to copy symlink "+external.Join("loop"))
+
+	t.Run("manifest", func(t *testing.T) {
+		fakeT := &fakeT{}
+		fs.Expected(fakeT, fs.FromDir(source.Path(), fs.CopyExternalSymlinks))
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg, "is a parent directory"))
+	})
+}
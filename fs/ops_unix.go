@@ -0,0 +1,93 @@
+// +build !windows
+
+package fs
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func mkfifo(path string) error {
+	if err := syscall.Mkfifo(path, defaultFileMode); err != nil {
+		return &os.PathError{Op: "mkfifo", Path: path, Err: err}
+	}
+	// Set the mode explicitly so that it does not depend on the umask
+	return os.Chmod(path, defaultFileMode)
+}
+
+func mksocket(path string) error {
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	listener.SetUnlinkOnClose(false)
+	if err := listener.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(path, defaultFileMode)
+}
+
+// copySpecial creates a named pipe or socket at dest to replace the one at
+// source. The contents of a named pipe, or the connections of a socket are
+// not copied.
+func copySpecial(source, dest string, info os.FileInfo) error {
+	switch {
+	case info.Mode()&os.ModeNamedPipe != 0:
+		return mkfifo(dest)
+	case info.Mode()&os.ModeSocket != 0:
+		return mksocket(dest)
+	default:
+		return errors.Errorf("can not copy %s: unsupported file mode %s", source, info.Mode())
+	}
+}
+
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	statT, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(statT.Uid), int(statT.Gid), true
+}
+
+// fileID identifies a file by its device and inode.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func newFileID(info os.FileInfo) (fileID, bool) {
+	statT, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(statT.Dev), ino: uint64(statT.Ino)}, true // nolint: unconvert
+}
+
+// readUmask returns the umask by creating a file with all the permission bits,
+// which avoids the race condition of using syscall.Umask to read the umask.
+func readUmask() (os.FileMode, error) {
+	dir, err := ioutil.TempDir("", "umask-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	filename := filepath.Join(dir, "file")
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	return os.ModePerm &^ info.Mode().Perm(), nil
+}
@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+func mkfifo(path string) error {
+	return errors.New("named pipes are not supported on windows")
+}
+
+func mksocket(path string) error {
+	return errors.New("unix sockets are not supported on windows")
+}
+
+func copySpecial(source, dest string, info os.FileInfo) error {
+	return errors.Errorf("can not copy %s: unsupported file mode %s", source, info.Mode())
+}
+
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// fileID identifies a file. The device and inode are not available from an
+// os.FileInfo on windows, so newFileID always returns false.
+type fileID struct{}
+
+func newFileID(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+func readUmask() (os.FileMode, error) {
+	return 0, nil
+}
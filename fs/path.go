@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -30,10 +31,21 @@ func (p *filePath) SetContent(content io.ReadCloser) {
 
 func (p *filePath) SetUID(uid uint32) {
 	p.file.uid = uid
+	p.file.tolerance.expectOwnership()
 }
 
 func (p *filePath) SetGID(gid uint32) {
 	p.file.gid = gid
+	p.file.tolerance.expectOwnership()
+}
+
+func (p *filePath) SetMtime(mtime time.Time) {
+	p.file.mtime = mtime
+	p.file.matchMtime = true
+}
+
+func (p *filePath) SetXattr(name, value string) {
+	p.file.setXattr(name, value)
 }
 
 type directoryPath struct {
@@ -43,10 +55,21 @@ type directoryPath struct {
 
 func (p *directoryPath) SetUID(uid uint32) {
 	p.directory.uid = uid
+	p.directory.tolerance.expectOwnership()
 }
 
 func (p *directoryPath) SetGID(gid uint32) {
 	p.directory.gid = gid
+	p.directory.tolerance.expectOwnership()
+}
+
+func (p *directoryPath) SetMtime(mtime time.Time) {
+	p.directory.mtime = mtime
+	p.directory.matchMtime = true
+}
+
+func (p *directoryPath) SetXattr(name, value string) {
+	p.directory.setXattr(name, value)
 }
 
 func (p *directoryPath) AddSymlink(path, target string) error {
@@ -57,6 +80,17 @@ func (p *directoryPath) AddSymlink(path, target string) error {
 	return nil
 }
 
+func (p *directoryPath) AddHardlink(path, target string) error {
+	p.directory.items[path] = &hardlink{target: target}
+	return nil
+}
+
+func (p *directoryPath) AddSpecial(path, kind string, ops ...PathOp) error {
+	newSpecial := &special{resource: newResource(0), kind: kind}
+	p.directory.items[path] = newSpecial
+	return applyPathOps(&specialPath{special: newSpecial}, ops)
+}
+
 func (p *directoryPath) AddFile(path string, ops ...PathOp) error {
 	newFile := &file{resource: newResource(0)}
 	p.directory.items[path] = newFile
@@ -65,10 +99,7 @@ func (p *directoryPath) AddFile(path string, ops ...PathOp) error {
 }
 
 func (p *directoryPath) AddGlobFiles(glob string, ops ...PathOp) error {
-	newFile := &file{resource: newResource(0)}
-	newFilePath := &filePath{file: newFile}
-	p.directory.filepathGlobs[glob] = newFilePath
-	return applyPathOps(newFilePath, ops)
+	return p.addPattern(newGlobPattern(glob, &file{resource: newResource(0)}), ops)
 }
 
 func (p *directoryPath) AddDirectory(path string, ops ...PathOp) error {
@@ -78,6 +109,49 @@ func (p *directoryPath) AddDirectory(path string, ops ...PathOp) error {
 	return applyPathOps(exp, ops)
 }
 
+// specialPath is a named pipe or socket in a manifest.
+type specialPath struct {
+	resourcePath
+	special *special
+}
+
+func (p *specialPath) SetMode(mode os.FileMode) {
+	switch p.special.kind {
+	case kindFifo:
+		mode |= os.ModeNamedPipe
+	case kindSocket:
+		mode |= os.ModeSocket
+	}
+	p.special.mode = mode
+}
+
+func (p *specialPath) SetUID(uid uint32) {
+	p.special.uid = uid
+	p.special.tolerance.expectOwnership()
+}
+
+func (p *specialPath) SetGID(gid uint32) {
+	p.special.gid = gid
+	p.special.tolerance.expectOwnership()
+}
+
+func (p *specialPath) SetMtime(mtime time.Time) {
+	p.special.mtime = mtime
+	p.special.matchMtime = true
+}
+
+func (p *specialPath) SetXattr(name, value string) {
+	p.special.setXattr(name, value)
+}
+
+func (r *resource) setXattr(name, value string) {
+	if r.xattrs == nil {
+		r.xattrs = make(map[string]string)
+	}
+	r.xattrs[name] = value
+	r.matchXattrs = true
+}
+
 // Expected returns a Manifest with a directory structured created by ops. The
 // PathOp operations are applied to the manifest as expectations of the
 // filesystem structure and properties.
@@ -94,9 +168,8 @@ func Expected(t assert.TestingT, ops ...PathOp) Manifest {
 
 func newDirectoryWithDefaults() *directory {
 	return &directory{
-		resource:      newResource(defaultRootDirMode),
-		items:         make(map[string]dirEntry),
-		filepathGlobs: make(map[string]*filePath),
+		resource: newResource(defaultRootDirMode),
+		items:    make(map[string]dirEntry),
 	}
 }
 
@@ -176,7 +249,13 @@ func MatchFileContent(f func([]byte) CompareResult) PathOp {
 }
 
 // MatchFilesWithGlob is a PathOp that updates a Manifest to match files using
-// glob pattern, and check them using the ops.
+// glob pattern, and check them using the ops. See filepath.Match for the glob
+// syntax.
+//
+// A glob may contain / to match files in subdirectories, and ** to match any
+// number of subdirectories. For example, MatchFilesWithGlob("**/*.go") matches
+// all the .go files in the directory tree. Subdirectories which may contain
+// matching files are allowed even if they are not otherwise expected.
 func MatchFilesWithGlob(glob string, ops ...PathOp) PathOp {
 	return func(path Path) error {
 		if m, ok := path.(*directoryPath); ok {
@@ -197,3 +276,19 @@ func MatchAnyFileMode(path Path) error {
 	}
 	return nil
 }
+
+// MatchTimestampsWithin is a PathOp that updates a Manifest so that the
+// modification time of the file or directory at path may differ from the time
+// set by WithTimestamps by up to tolerance. Without MatchTimestampsWithin the
+// modification time must be equal.
+func MatchTimestampsWithin(tolerance time.Duration) PathOp {
+	return func(path Path) error {
+		switch m := path.(type) {
+		case *filePath:
+			m.file.mtimeTolerance = tolerance
+		case *directoryPath:
+			m.directory.mtimeTolerance = tolerance
+		}
+		return nil
+	}
+}
@@ -0,0 +1,235 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// entryPattern matches entries in a directory by name. Every entry which
+// matches the pattern, and has the same type as expected, is compared to
+// expected.
+type entryPattern struct {
+	// pattern is the glob or regular expression used to create the pattern
+	pattern string
+	// segments are the slash separated parts of a glob. A glob with more than
+	// one segment, or with a ** segment, matches entries in subdirectories.
+	segments []string
+	regexp   *regexp.Regexp
+	// expected is a *file or a *directory
+	expected dirEntry
+}
+
+func (p *entryPattern) String() string {
+	kind := "glob"
+	if p.regexp != nil {
+		kind = "regexp"
+	}
+	if _, ok := p.expected.(*directory); ok {
+		kind = "directory " + kind
+	}
+	return fmt.Sprintf("%s %q", kind, p.pattern)
+}
+
+// matchName returns true if the pattern matches an entry named name in the
+// directory which contains the pattern.
+func (p *entryPattern) matchName(name string) (bool, error) {
+	if p.regexp != nil {
+		return p.regexp.MatchString(name), nil
+	}
+	return matchSegments(p.segments, []string{name})
+}
+
+// matchSegments matches the glob segments to the names in a path. A **
+// segment matches zero or more names.
+func matchSegments(segments []string, names []string) (bool, error) {
+	switch {
+	case len(segments) == 0:
+		return len(names) == 0, nil
+	case segments[0] == "**":
+		for i := 0; i <= len(names); i++ {
+			if ok, err := matchSegments(segments[1:], names[i:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	case len(names) == 0:
+		return false, nil
+	}
+	ok, err := filepath.Match(segments[0], names[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(segments[1:], names[1:])
+}
+
+// subdirSegments returns the segments of the globs which match entries in
+// the subdirectory name.
+func subdirSegments(segments []string, name string) [][]string {
+	switch {
+	case len(segments) == 0:
+		return nil
+	case segments[0] == "**":
+		// ** matches name, or matches nothing
+		return append([][]string{segments}, subdirSegments(segments[1:], name)...)
+	}
+	if ok, _ := filepath.Match(segments[0], name); ok && len(segments) > 1 {
+		return [][]string{segments[1:]}
+	}
+	return nil
+}
+
+// subdirPatterns returns the patterns which match entries in the subdirectory
+// name, created from the globs in patterns which match more than a single name.
+func subdirPatterns(patterns []*entryPattern, name string) []*entryPattern {
+	var result []*entryPattern
+	for _, pattern := range patterns {
+		if pattern.regexp != nil {
+			continue
+		}
+		for _, segments := range subdirSegments(pattern.segments, name) {
+			derived := *pattern
+			derived.segments = segments
+			result = append(result, &derived)
+		}
+	}
+	return result
+}
+
+// withSubdirPatterns returns entry with the patterns that apply to the
+// subdirectory name added to it, if entry is a directory.
+func withSubdirPatterns(entry dirEntry, patterns []*entryPattern, name string) dirEntry {
+	dir, ok := entry.(*directory)
+	if !ok {
+		return entry
+	}
+	sub := subdirPatterns(patterns, name)
+	if len(sub) == 0 {
+		return entry
+	}
+	copied := *dir
+	copied.patterns = append(append([]*entryPattern{}, dir.patterns...), sub...)
+	return &copied
+}
+
+func (p *directoryPath) addPattern(pattern *entryPattern, ops []PathOp) error {
+	p.directory.patterns = append(p.directory.patterns, pattern)
+	var err error
+	switch expected := pattern.expected.(type) {
+	case *file:
+		err = applyPathOps(&filePath{file: expected}, ops)
+	case *directory:
+		err = applyPathOps(&directoryPath{directory: expected}, ops)
+	}
+	if err != nil {
+		return err
+	}
+	// The expected entry is compared to every matching entry, so the content
+	// must be read into memory.
+	return bufferContents(pattern.expected)
+}
+
+func bufferContents(entry dirEntry) error {
+	switch typed := entry.(type) {
+	case *file:
+		if typed.content == nil || typed.content == anyFileContent {
+			return nil
+		}
+		raw, err := readAllContent(typed.content)
+		if err != nil {
+			return err
+		}
+		typed.content = newBufferedContent(raw)
+	case *directory:
+		for _, item := range typed.items {
+			if err := bufferContents(item); err != nil {
+				return err
+			}
+		}
+		for _, pattern := range typed.patterns {
+			if err := bufferContents(pattern.expected); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newGlobPattern(glob string, expected dirEntry) *entryPattern {
+	return &entryPattern{
+		pattern:  glob,
+		segments: strings.Split(glob, "/"),
+		expected: expected,
+	}
+}
+
+func newRegexpPattern(expr string, expected dirEntry) (*entryPattern, error) {
+	// the expression must match the whole name, not only part of it
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid regular expression %q", expr)
+	}
+	return &entryPattern{pattern: expr, regexp: re, expected: expected}, nil
+}
+
+// MatchFilesWithRegexp is a PathOp that updates a Manifest to match files with
+// a name that matches the regular expression, and check them using the ops.
+// The regular expression is matched against the name of the file, not the
+// path, and must match the whole name, as if it started with ^ and ended
+// with $.
+func MatchFilesWithRegexp(expr string, ops ...PathOp) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(*directoryPath); ok {
+			pattern, err := newRegexpPattern(expr, &file{resource: newResource(0)})
+			if err != nil {
+				return err
+			}
+			return m.addPattern(pattern, ops)
+		}
+		return nil
+	}
+}
+
+// MatchDirsWithGlob is a PathOp that updates a Manifest to match directories
+// using a glob pattern, and check them using the ops. Every matching directory
+// must have the structure defined by ops. Like WithDir, the default mode of the
+// directories is 0755.
+//
+// See MatchFilesWithGlob for the glob syntax.
+func MatchDirsWithGlob(glob string, ops ...PathOp) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(*directoryPath); ok {
+			ops = append([]PathOp{WithMode(defaultDirMode)}, ops...)
+			return m.addPattern(newGlobPattern(glob, newDirectoryWithDefaults()), ops)
+		}
+		return nil
+	}
+}
+
+// MatchDirsWithRegexp is a PathOp that updates a Manifest to match directories
+// with a name that matches the regular expression, and check them using the
+// ops. Like MatchFilesWithRegexp, the regular expression must match the whole
+// name. See MatchDirsWithGlob.
+func MatchDirsWithRegexp(expr string, ops ...PathOp) PathOp {
+	return func(path Path) error {
+		if m, ok := path.(*directoryPath); ok {
+			pattern, err := newRegexpPattern(expr, newDirectoryWithDefaults())
+			if err != nil {
+				return err
+			}
+			ops = append([]PathOp{WithMode(defaultDirMode)}, ops...)
+			return m.addPattern(pattern, ops)
+		}
+		return nil
+	}
+}
+
+// MatchExtraDirs is a PathOp that updates a Manifest to allow a directory to
+// contain unspecified directories, with any mode, ownership, and contents.
+// Unlike MatchExtraFiles, unspecified files are still reported.
+func MatchExtraDirs(path Path) error {
+	return MatchDirsWithGlob("*", MatchAnyFileMode, IgnoreOwnership, MatchExtraFiles)(path)
+}
@@ -0,0 +1,176 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// ReadFile returns the content of the file at name, a slash separated path
+// relative to the directory. The test fails if the file can not be read.
+func (d *Dir) ReadFile(t assert.TestingT, name string) []byte {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	return readFile(t, d.path, name)
+}
+
+// ReadFile returns the content of the file. The test fails if the file can not
+// be read.
+func (f *File) ReadFile(t assert.TestingT) []byte {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	return readFile(t, filepath.Dir(f.path), filepath.Base(f.path))
+}
+
+func readFile(t assert.TestingT, root, name string) []byte {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+	assert.NilError(t, relativeError(name, err))
+	return content
+}
+
+// AssertFile compares the file at name, a slash separated path relative to the
+// directory, to the expectations defined by ops. The ops are applied the same
+// way they are applied to a file in a Manifest, but only the properties set by
+// the ops are compared. For example:
+//
+//     dir.AssertFile(t, "config/app.yaml",
+//         fs.WithMode(0600), fs.ContentContains("debug: true"))
+//
+// The test fails if the file does not match.
+func (d *Dir) AssertFile(t assert.TestingT, name string, ops ...PathOp) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	assert.Assert(t, fileEqual(d.path, name, ops))
+}
+
+// AssertFile compares the file to the expectations defined by ops. See
+// Dir.AssertFile.
+func (f *File) AssertFile(t assert.TestingT, ops ...PathOp) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	assert.Assert(t, fileEqual(filepath.Dir(f.path), filepath.Base(f.path), ops))
+}
+
+// EqualFile compares the file at path to the expectations defined by ops, and
+// returns success if they match. Only the properties set by the ops are
+// compared, see Dir.AssertFile.
+//
+// The ops are applied every time the comparison runs, so the comparison can be
+// used more than once, for example with poll.Compare.
+//
+// EqualFile is a cmp.Comparison which can be used with assert.Assert().
+func EqualFile(path string, ops ...PathOp) cmp.Comparison {
+	return fileEqual("", filepath.ToSlash(path), ops)
+}
+
+func fileEqual(root, name string, ops []PathOp) cmp.Comparison {
+	return func() cmp.Result {
+		ignoreOwnership := true
+		expected := &file{
+			resource: newResource(anyFileMode),
+			content:  anyFileContent,
+		}
+		expected.tolerance.ignoreOwnership = &ignoreOwnership
+		if err := applyPathOps(&filePath{file: expected}, ops); err != nil {
+			return cmp.ResultFromError(err)
+		}
+
+		fullpath := filepath.Join(root, filepath.FromSlash(name))
+		info, err := os.Lstat(fullpath)
+		if err != nil {
+			return cmp.ResultFromError(relativeError(name, err))
+		}
+		entry, err := getTypedResource(fullpath, info, expected.matchXattrs)
+		if err != nil {
+			return cmp.ResultFromError(relativeError(name, err))
+		}
+		actual, ok := entry.(*file)
+		if !ok {
+			return cmp.ResultFailure(fmt.Sprintf("%s: expected file got %s", name, entry.Type()))
+		}
+		defer actual.content.Close() // nolint: errcheck
+
+		problems := eqFile(expected, actual)
+		if len(problems) == 0 {
+			return cmp.ResultSuccess
+		}
+		buf := new(bytes.Buffer)
+		fmt.Fprintf(buf, "file %s does not match expected:\n", name)
+		for _, p := range problems {
+			buf.WriteString("  " + string(p) + "\n")
+		}
+		return cmp.ResultFailure(buf.String())
+	}
+}
+
+// List returns the slash separated paths of all the files, directories, and
+// symlinks in the directory tree, relative to the directory, in sorted order.
+// The paths of directories end with a slash.
+func (d *Dir) List(t assert.TestingT) []string {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	var names []string
+	d.Walk(t, func(name string, info os.FileInfo) error {
+		if info.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Walk calls fn for every file, directory, and symlink in the directory tree,
+// in lexical order, like filepath.Walk. name is the slash separated path
+// relative to the directory, and the directory itself is not included. If fn
+// returns filepath.SkipDir the directory is skipped. The test fails if fn
+// returns any other error, or if the directory tree can not be read.
+func (d *Dir) Walk(t assert.TestingT, fn func(name string, info os.FileInfo) error) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	err := filepath.Walk(d.path, func(path string, info os.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(d.path, path)
+		if relErr != nil {
+			return relErr
+		}
+		name := filepath.ToSlash(rel)
+		switch {
+		case err != nil:
+			return relativeError(name, err)
+		case name == ".":
+			return nil
+		}
+		err = fn(name, info)
+		if err != nil && err != filepath.SkipDir {
+			return errors.Wrap(err, name)
+		}
+		return err
+	})
+	assert.NilError(t, err)
+}
+
+// relativeError replaces the full path in err with name, so that the error
+// does not include the path of the temporary directory.
+func relativeError(name string, err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		return errors.Errorf("%s %s: %s", pathErr.Op, name, pathErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/skip"
+)
+
+func TestDirReadFile(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithDir("a", fs.WithFile("b", "content b")))
+	defer dir.Remove()
+
+	assert.Equal(t, string(dir.ReadFile(t, "a/b")), "content b")
+
+	fakeT := &fakeT{}
+	dir.ReadFile(fakeT, "a/missing")
+	assert.Assert(t, fakeT.failed)
+	assert.Assert(t, is.Contains(fakeT.msg, "open a/missing: "))
+	assert.Assert(t, !strings.Contains(fakeT.msg, dir.Path()))
+}
+
+func TestFileReadFile(t *testing.T) {
+	file := fs.NewFile(t, t.Name(), fs.WithContent("content"))
+	defer file.Remove()
+
+	assert.Equal(t, string(file.ReadFile(t)), "content")
+}
+
+func TestDirAssertFile(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithDir("config",
+			fs.WithFile("app.yaml", "debug: true\n", fs.WithMode(0600))),
+		fs.WithSymlink("link", "config"))
+	defer dir.Remove()
+
+	t.Run("match", func(t *testing.T) {
+		dir.AssertFile(t, "config/app.yaml")
+		dir.AssertFile(t, "config/app.yaml",
+			fs.WithContent("debug: true\n"),
+			fs.ContentContains("debug"))
+	})
+
+	t.Run("content does not match", func(t *testing.T) {
+		fakeT := &fakeT{}
+		dir.AssertFile(fakeT, "config/app.yaml", fs.ContentContains("verbose"))
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg, `file config/app.yaml does not match expected:
+  content: does not contain "verbose"`))
+	})
+
+	t.Run("mode does not match", func(t *testing.T) {
+		skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+		fakeT := &fakeT{}
+		dir.AssertFile(fakeT, "config/app.yaml", fs.WithMode(0644))
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg, `file config/app.yaml does not match expected:
+  mode: expected -rw-r--r-- got -rw-------`))
+	})
+
+	t.Run("not a file", func(t *testing.T) {
+		linkT := &fakeT{}
+		dir.AssertFile(linkT, "link")
+		assert.Assert(t, linkT.failed)
+		assert.Assert(t, is.Contains(linkT.msg, "link: expected file got symlink"))
+
+		missingT := &fakeT{}
+		dir.AssertFile(missingT, "config/missing")
+		assert.Assert(t, missingT.failed)
+		assert.Assert(t, is.Contains(missingT.msg, "lstat config/missing: "))
+	})
+}
+
+func TestFileAssertFile(t *testing.T) {
+	file := fs.NewFile(t, t.Name(), fs.WithContent(`{"a": 1}`))
+	defer file.Remove()
+
+	file.AssertFile(t, fs.ContentJSONEqual(`{"a":1}`))
+	assert.Assert(t, fs.EqualFile(file.Path(), fs.ContentContains(`"a"`)))
+
+	fakeT := &fakeT{}
+	file.AssertFile(fakeT, fs.ContentSize(1))
+	assert.Assert(t, fakeT.failed)
+	assert.Assert(t, is.Contains(fakeT.msg, "content: expected 1 bytes got 8"))
+}
+
+func TestDirList(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("file1", ""),
+		fs.WithDir("sub",
+			fs.WithFile("file2", ""),
+			fs.WithDir("empty")),
+		fs.WithSymlink("link", "file1"))
+	defer dir.Remove()
+
+	expected := []string{"file1", "link", "sub/", "sub/empty/", "sub/file2"}
+	assert.DeepEqual(t, dir.List(t), expected)
+}
+
+func TestDirWalk(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("file1", ""),
+		fs.WithDir("skip", fs.WithFile("file2", "")),
+		fs.WithDir("sub", fs.WithFile("file3", "")))
+	defer dir.Remove()
+
+	t.Run("skip dir", func(t *testing.T) {
+		var names []string
+		dir.Walk(t, func(name string, info os.FileInfo) error {
+			if name == "skip" {
+				return filepath.SkipDir
+			}
+			names = append(names, name)
+			return nil
+		})
+		assert.DeepEqual(t, names, []string{"file1", "sub", "sub/file3"})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		fakeT := &fakeT{}
+		dir.Walk(fakeT, func(name string, info os.FileInfo) error {
+			if name == "sub/file3" {
+				return errors.New("something failed")
+			}
+			return nil
+		})
+		assert.Assert(t, fakeT.failed)
+		assert.Assert(t, is.Contains(fakeT.msg, "sub/file3: something failed"))
+	})
+}
@@ -3,12 +3,14 @@ package fs
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/internal/format"
@@ -19,31 +21,81 @@ import (
 // will contain all the differences between the directory structure and the
 // expected structure defined by the Manifest.
 //
+// The failure message is a tree of the entries which do not match, after a
+// summary of the number of failures. Missing entries are marked with -,
+// unexpected entries with +, and entries which are different with ~. At most 10
+// content diffs are included in the message. Set GOTESTTOOLS_FS_FULL_REPORT=true
+// to include all of them, or set GOTESTTOOLS_FS_REPORT_FILE to the name of a
+// file to append the full report to the file.
+//
 // Equal is a cmp.Comparison which can be used with assert.Assert().
 func Equal(path string, expected Manifest) cmp.Comparison {
 	return func() cmp.Result {
-		actual, err := manifestFromDir(path)
+		// the expected content is read into memory so that the comparison
+		// can be used more than once.
+		if err := bufferContents(expected.root); err != nil {
+			return cmp.ResultFromError(err)
+		}
+		actual, err := manifestFromDir(path, matchesXattrs(expected.root))
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		failures := eqDirectory(string(os.PathSeparator), expected.root, actual.root)
+		if len(failures) == 0 {
+			return cmp.ResultSuccess
+		}
+		msg := fmt.Sprintf("directory %s does not match expected", path)
+		return cmp.ResultFailure(formatReport(msg, failures))
+	}
+}
+
+// EqualDirectory compares the directory at path to the directory at
+// expectedPath and returns success if they match. All the files, directories,
+// and symlinks in both directories are compared the same way as Equal compares
+// a directory to a Manifest. The properties of the two root directories are
+// not compared, only their contents.
+//
+// EqualDirectory is a cmp.Comparison which can be used with assert.Assert().
+func EqualDirectory(path string, expectedPath string) cmp.Comparison {
+	return func() cmp.Result {
+		expected, err := manifestFromDir(expectedPath, false)
 		if err != nil {
 			return cmp.ResultFromError(err)
 		}
+		actual, err := manifestFromDir(path, false)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		expected.root.resource = actual.root.resource
 		failures := eqDirectory(string(os.PathSeparator), expected.root, actual.root)
 		if len(failures) == 0 {
 			return cmp.ResultSuccess
 		}
-		msg := fmt.Sprintf("directory %s does not match expected:\n", path)
-		return cmp.ResultFailure(msg + formatFailures(failures))
+		msg := fmt.Sprintf("directory %s does not match %s", path, expectedPath)
+		return cmp.ResultFailure(formatReport(msg, failures))
 	}
 }
 
 type failure struct {
 	path     string
+	kind     failureKind
 	problems []problem
 }
 
+// failureKind identifies how an entry in a directory does not match the
+// expected entry.
+type failureKind int
+
+const (
+	failureChanged failureKind = iota
+	failureMissing
+	failureExtra
+)
+
 type problem string
 
 func notEqual(property string, x, y interface{}) problem {
-	return problem(fmt.Sprintf("%s: expected %s got %s", property, x, y))
+	return problem(fmt.Sprintf("%s: expected %v got %v", property, x, y))
 }
 
 func errProblem(reason string, err error) problem {
@@ -56,18 +108,85 @@ func existenceProblem(filename, reason string, args ...interface{}) problem {
 
 func eqResource(x, y resource) []problem {
 	var p []problem
-	if x.uid != y.uid {
+	if !x.tolerance.ignoresOwnership() && x.uid != y.uid {
 		p = append(p, notEqual("uid", x.uid, y.uid))
 	}
-	if x.gid != y.gid {
+	if !x.tolerance.ignoresOwnership() && x.gid != y.gid {
 		p = append(p, notEqual("gid", x.gid, y.gid))
 	}
-	if x.mode != anyFileMode && x.mode != y.mode {
-		p = append(p, notEqual("mode", x.mode, y.mode))
+	if x.mode != anyFileMode {
+		if xMode, yMode := x.tolerance.modes(x.mode, y.mode); xMode != yMode {
+			p = append(p, notEqual("mode", xMode, yMode))
+		}
+	}
+	if x.matchMtime && !mtimeWithin(x.mtime, y.mtime, x.mtimeTolerance) {
+		p = append(p, notEqual("mtime",
+			x.mtime.UTC().Format(time.RFC3339Nano), y.mtime.UTC().Format(time.RFC3339Nano)))
+	}
+	if x.matchXattrs {
+		p = append(p, eqXattrs(x.xattrs, y.xattrs)...)
 	}
 	return p
 }
 
+// matchesXattrs returns true if entry, or any entry it contains, has expected
+// extended attributes.
+func matchesXattrs(entry dirEntry) bool {
+	switch typed := entry.(type) {
+	case *file:
+		return typed.matchXattrs
+	case *special:
+		return typed.matchXattrs
+	case *directory:
+		if typed.matchXattrs {
+			return true
+		}
+		for _, item := range typed.items {
+			if matchesXattrs(item) {
+				return true
+			}
+		}
+		for _, pattern := range typed.patterns {
+			if matchesXattrs(pattern.expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eqXattrs compares only the extended attributes which are in x
+func eqXattrs(x, y map[string]string) []problem {
+	var p []problem
+	for _, name := range sortedStrings(x) {
+		yValue, ok := y[name]
+		switch {
+		case !ok:
+			p = append(p, existenceProblem("xattr "+name, "missing, expected value %q", x[name]))
+		case x[name] != yValue:
+			p = append(p, problem(fmt.Sprintf("xattr %s: expected %q got %q", name, x[name], yValue)))
+		}
+	}
+	return p
+}
+
+func sortedStrings(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func mtimeWithin(x, y time.Time, tolerance time.Duration) bool {
+	delta := x.Sub(y)
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta <= tolerance
+}
+
 func removeCarriageReturn(in []byte) []byte {
 	return bytes.Replace(in, []byte("\r\n"), []byte("\n"), -1)
 }
@@ -76,40 +195,41 @@ func removeCarriageReturn(in []byte) []byte {
 func eqFile(x, y *file) []problem {
 	p := eqResource(x.resource, y.resource)
 
+	// the content matchers are used with the exact content, if there is one
+	matchExact := x.content != nil && x.content != anyFileContent
 	switch {
-	case x.content == nil:
+	case x.content == nil && len(x.contentMatchers) == 0:
 		p = append(p, existenceProblem("content", "expected content is nil"))
 		return p
-	case x.content == anyFileContent:
+	case !matchExact && len(x.contentMatchers) == 0:
 		return p
 	case y.content == nil:
 		p = append(p, existenceProblem("content", "actual content is nil"))
 		return p
 	}
 
-	xContent, xErr := ioutil.ReadAll(x.content)
-	defer x.content.Close()
-	yContent, yErr := ioutil.ReadAll(y.content)
-	defer y.content.Close()
-
-	if xErr != nil {
-		p = append(p, errProblem("failed to read expected content", xErr))
+	yContent, err := readAllContent(y.content)
+	if err != nil {
+		return append(p, errProblem("failed to read actual content", err))
 	}
-	if yErr != nil {
-		p = append(p, errProblem("failed to read actual content", xErr))
-	}
-	if xErr != nil || yErr != nil {
+	p = append(p, eqContentFuncs(x.contentMatchers, yContent)...)
+	if !matchExact {
 		return p
 	}
 
+	// MatchFileContent replaces the comparison to the exact content
 	if x.compareContentFunc != nil {
-		r := x.compareContentFunc(yContent)
-		if !r.Success() {
+		if r := x.compareContentFunc(yContent); !r.Success() {
 			p = append(p, existenceProblem("content", r.FailureMessage()))
 		}
 		return p
 	}
 
+	xContent, err := readAllContent(x.content)
+	if err != nil {
+		return append(p, errProblem("failed to read expected content", err))
+	}
+
 	if x.ignoreCariageReturn || y.ignoreCariageReturn {
 		xContent = removeCarriageReturn(xContent)
 		yContent = removeCarriageReturn(yContent)
@@ -121,6 +241,26 @@ func eqFile(x, y *file) []problem {
 	return p
 }
 
+func eqContentFuncs(funcs []func([]byte) CompareResult, content []byte) []problem {
+	var p []problem
+	for _, f := range funcs {
+		if r := f(content); !r.Success() {
+			p = append(p, existenceProblem("content", r.FailureMessage()))
+		}
+	}
+	return p
+}
+
+// readAllContent reads and closes content. Buffered content is not consumed,
+// so that it can be compared to more than one file.
+func readAllContent(content io.ReadCloser) ([]byte, error) {
+	if c, ok := content.(*bufferedContent); ok {
+		return c.raw, nil
+	}
+	defer content.Close() // nolint: errcheck
+	return ioutil.ReadAll(content)
+}
+
 func diffContent(x, y []byte) problem {
 	diff := format.UnifiedDiff(format.DiffConfig{
 		A:    string(x),
@@ -129,9 +269,9 @@ func diffContent(x, y []byte) problem {
 		To:   "actual",
 	})
 	// Remove the trailing newline in the diff. A trailing newline is always
-	// added to a problem by formatFailures.
+	// added to a problem by formatReport.
 	diff = strings.TrimSuffix(diff, "\n")
-	return problem("content:\n" + indent(diff, "    "))
+	return problem(contentDiffPrefix + indent(diff, "    "))
 }
 
 func indent(s, prefix string) string {
@@ -158,6 +298,7 @@ func eqSymlink(x, y *symlink) []problem {
 }
 
 func eqDirectory(path string, x, y *directory) []failure {
+	inheritTolerance(x)
 	p := eqResource(x.resource, y.resource)
 	var f []failure // nolint: prealloc
 	matchedFiles := make(map[string]bool)
@@ -170,21 +311,38 @@ func eqDirectory(path string, x, y *directory) []failure {
 		xEntry := x.items[name]
 		yEntry, ok := y.items[name]
 		if !ok {
-			p = append(p, existenceProblem(name, "expected %s to exist", xEntry.Type()))
+			f = append(f, failure{
+				path:     filepath.Join(path, name),
+				kind:     failureMissing,
+				problems: []problem{problem(fmt.Sprintf("expected %s to exist", xEntry.Type()))},
+			})
 			continue
 		}
 
 		if xEntry.Type() != yEntry.Type() {
-			p = append(p, notEqual(name, xEntry.Type(), yEntry.Type()))
+			f = append(f, failure{
+				path:     filepath.Join(path, name),
+				problems: []problem{notEqual("type", xEntry.Type(), yEntry.Type())},
+			})
+			continue
+		}
+
+		if link, ok := xEntry.(*hardlink); ok {
+			problems := eqHardlink(link, yEntry.(*file), y)
+			f = maybeAppendFailure(f, filepath.Join(path, name), problems)
 			continue
 		}
 
+		xEntry = withSubdirPatterns(xEntry, x.patterns, name)
 		f = append(f, eqEntry(filepath.Join(path, name), xEntry, yEntry)...)
 	}
 
-	if len(x.filepathGlobs) != 0 {
+	if len(x.patterns) != 0 {
 		for _, name := range sortedKeys(y.items) {
-			m := matchGlob(name, y.items[name], x.filepathGlobs)
+			if matchedFiles[name] {
+				continue
+			}
+			m := matchPatterns(filepath.Join(path, name), name, y.items[name], x)
 			matchedFiles[name] = m.match
 			f = append(f, m.failures...)
 		}
@@ -195,12 +353,59 @@ func eqDirectory(path string, x, y *directory) []failure {
 	}
 	for _, name := range sortedKeys(y.items) {
 		if !matchedFiles[name] {
-			p = append(p, existenceProblem(name, "unexpected %s", y.items[name].Type()))
+			f = append(f, failure{
+				path:     filepath.Join(path, name),
+				kind:     failureExtra,
+				problems: []problem{unexpectedEntry(y.items[name], x.patterns)},
+			})
 		}
 	}
 	return maybeAppendFailure(f, path, p)
 }
 
+// unexpectedEntry returns a problem for an entry which was not expected, and
+// did not match any of the patterns.
+func unexpectedEntry(entry dirEntry, patterns []*entryPattern) problem {
+	if len(patterns) == 0 {
+		return problem("unexpected " + entry.Type())
+	}
+	seen := make(map[string]bool)
+	descriptions := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if description := pattern.String(); !seen[description] {
+			seen[description] = true
+			descriptions = append(descriptions, description)
+		}
+	}
+	return problem(fmt.Sprintf("unexpected %s, does not match %s",
+		entry.Type(), strings.Join(descriptions, ", ")))
+}
+
+// inheritTolerance sets the tolerance of every entry in the directory, and
+// every entry matched by a pattern, to inherit from the tolerance of the
+// directory.
+func inheritTolerance(dir *directory) {
+	for _, entry := range dir.items {
+		inheritEntryTolerance(entry, dir.tolerance)
+	}
+	for _, pattern := range dir.patterns {
+		inheritEntryTolerance(pattern.expected, dir.tolerance)
+	}
+}
+
+func inheritEntryTolerance(entry dirEntry, parent tolerance) {
+	switch typed := entry.(type) {
+	case *file:
+		typed.tolerance = typed.tolerance.inherit(parent)
+	case *symlink:
+		typed.tolerance = typed.tolerance.inherit(parent)
+	case *special:
+		typed.tolerance = typed.tolerance.inherit(parent)
+	case *directory:
+		typed.tolerance = typed.tolerance.inherit(parent)
+	}
+}
+
 func maybeAppendFailure(failures []failure, path string, problems []problem) []failure {
 	if len(problems) > 0 {
 		return append(failures, failure{path: path, problems: problems})
@@ -217,6 +422,45 @@ func sortedKeys(items map[string]dirEntry) []string {
 	return keys
 }
 
+// eqHardlink compares y to the file at the target of the hardlink in dir, which
+// is the actual directory that contains y.
+func eqHardlink(x *hardlink, y *file, dir *directory) []problem {
+	if y.content != nil {
+		y.content.Close() // nolint: errcheck
+	}
+	target, ok := lookupEntry(dir, x.target).(*file)
+	switch {
+	case !ok:
+		return []problem{existenceProblem("hardlink", "expected target %s to be a file", x.target)}
+	case !os.SameFile(y.info, target.info):
+		return []problem{existenceProblem("hardlink", "expected a link to %s", x.target)}
+	}
+	return nil
+}
+
+// lookupEntry returns the entry at the slash separated path relative to dir,
+// or nil if the entry does not exist.
+func lookupEntry(dir *directory, path string) dirEntry {
+	var entry dirEntry = dir
+	for _, name := range strings.Split(filepath.ToSlash(path), "/") {
+		if name == "." || name == "" {
+			continue
+		}
+		current, ok := entry.(*directory)
+		switch {
+		case !ok:
+			return nil
+		case name == ".." && current.parent == nil:
+			return nil
+		case name == "..":
+			entry = current.parent
+		default:
+			entry = current.items[name]
+		}
+	}
+	return entry
+}
+
 // eqEntry assumes x and y to be the same type
 func eqEntry(path string, x, y dirEntry) []failure {
 	resp := func(problems []problem) []failure {
@@ -231,6 +475,8 @@ func eqEntry(path string, x, y dirEntry) []failure {
 		return resp(eqFile(typed, y.(*file)))
 	case *symlink:
 		return resp(eqSymlink(typed, y.(*symlink)))
+	case *special:
+		return resp(eqResource(typed.resource, y.(*special).resource))
 	case *directory:
 		return eqDirectory(path, typed, y.(*directory))
 	}
@@ -242,36 +488,44 @@ type globMatch struct {
 	failures []failure
 }
 
-func matchGlob(name string, yEntry dirEntry, globs map[string]*filePath) globMatch {
+// matchPatterns compares yEntry to the first pattern in dir which matches
+// name, and has the same type as yEntry. Subdirectories which do not match a
+// pattern are compared to the patterns which match entries in subdirectories.
+func matchPatterns(path, name string, yEntry dirEntry, dir *directory) globMatch {
 	m := globMatch{}
 
-	for glob, expectedFile := range globs {
-		ok, err := filepath.Match(glob, name)
+	for _, pattern := range dir.patterns {
+		ok, err := pattern.matchName(name)
 		if err != nil {
 			p := errProblem("failed to match glob pattern", err)
-			f := failure{path: name, problems: []problem{p}}
-			m.failures = append(m.failures, f)
+			m.failures = append(m.failures, failure{path: path, problems: []problem{p}})
+			continue
 		}
-		if ok {
+		if ok && pattern.expected.Type() == yEntry.Type() {
 			m.match = true
-			m.failures = eqEntry(name, expectedFile.file, yEntry)
+			xEntry := withSubdirPatterns(pattern.expected, dir.patterns, name)
+			m.failures = append(m.failures, eqEntry(path, xEntry, yEntry)...)
 			return m
 		}
 	}
-	return m
-}
 
-func formatFailures(failures []failure) string {
-	sort.Slice(failures, func(i, j int) bool {
-		return failures[i].path < failures[j].path
-	})
-
-	buf := new(bytes.Buffer)
-	for _, failure := range failures {
-		buf.WriteString(failure.path + "\n")
-		for _, problem := range failure.problems {
-			buf.WriteString("  " + string(problem) + "\n")
+	yDir, ok := yEntry.(*directory)
+	if !ok {
+		return m
+	}
+	if patterns := subdirPatterns(dir.patterns, name); len(patterns) > 0 {
+		implicit := &directory{
+			resource: resource{
+				mode:      anyFileMode,
+				uid:       yDir.uid,
+				gid:       yDir.gid,
+				tolerance: dir.tolerance,
+			},
+			items:    make(map[string]dirEntry),
+			patterns: patterns,
 		}
+		m.match = true
+		m.failures = append(m.failures, eqDirectory(path, implicit, yDir)...)
 	}
-	return buf.String()
+	return m
 }
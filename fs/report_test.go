@@ -2,12 +2,17 @@ package fs
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/env"
 	"gotest.tools/v3/skip"
 )
 
@@ -27,14 +32,14 @@ func TestEqualModeMismatch(t *testing.T) {
 
 	result := Equal(dir.Path(), Expected(t))()
 	assert.Assert(t, !result.Success())
-	expected := fmtExpected(`directory %s does not match expected:
-/
-  mode: expected drwx------ got dr-x------
+	expected := fmtExpected(`directory %s does not match expected (1 changed):
+~ /
+    mode: expected drwx------ got dr-x------
 `, dir.Path())
 	if runtime.GOOS == "windows" {
-		expected = fmtExpected(`directory %s does not match expected:
-\
-  mode: expected drwxrwxrwx got dr-xr-xr-x
+		expected = fmtExpected(`directory %s does not match expected (1 changed):
+~ \
+    mode: expected drwxrwxrwx got dr-xr-xr-x
 `, dir.Path())
 	}
 	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
@@ -65,10 +70,12 @@ func TestEqualDirectoryHasWithExtraFiles(t *testing.T) {
 	manifest := Expected(t, WithFile("file1", "content"))
 	result := Equal(dir.Path(), manifest)()
 	assert.Assert(t, !result.Success())
-	expected := fmtExpected(`directory %s does not match expected:
+	expected := fmtExpected(`directory %s does not match expected (1 missing, 1 unexpected):
 /
-  file1: expected file to exist
-  extra1: unexpected file
+├── - file1
+│       expected file to exist
+└── + extra1
+        unexpected file
 `, dir.Path())
 	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 }
@@ -96,15 +103,16 @@ func TestEqualWithFileContent(t *testing.T) {
 		WithFile("file1", "line2\nline3"))
 
 	result := Equal(dir.Path(), manifest)()
-	expected := fmtExpected(`directory %s does not match expected:
-/file1
-  content:
-    --- expected
-    +++ actual
-    @@ -1,2 +1,3 @@
-    +line1
-     line2
-     line3
+	expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ file1
+        content:
+            --- expected
+            +++ actual
+            @@ -1,2 +1,3 @@
+            +line1
+             line2
+             line3
 `, dir.Path())
 	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 }
@@ -147,18 +155,21 @@ func TestEqualManyFailures(t *testing.T) {
 	result := Equal(dir.Path(), manifest)()
 	assert.Assert(t, !result.Success())
 
-	expected := fmtExpected(`directory %s does not match expected:
+	expected := fmtExpected(`directory %s does not match expected (1 missing, 2 unexpected, 1 changed):
 /
-  subdir: expected directory to exist
-  extra: unexpected file
-  sym1: unexpected symlink
-/file1
-  content:
-    --- expected
-    +++ actual
-    @@ -1,2 +1 @@
-    -not the
-     same in both
+├── - subdir
+│       expected directory to exist
+├── + extra
+│       unexpected file
+├── + sym1
+│       unexpected symlink
+└── ~ file1
+        content:
+            --- expected
+            +++ actual
+            @@ -1,2 +1 @@
+            -not the
+             same in both
 `, dir.Path())
 	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 }
@@ -201,9 +212,10 @@ func TestMatchFileContent(t *testing.T) {
 		result := Equal(dir.Path(), manifest)()
 		assert.Assert(t, !result.Success())
 
-		expected := fmtExpected(`directory %s does not match expected:
-/data
-  content: data content differs from expected
+		expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ data
+        content: data content differs from expected
 `, dir.Path())
 		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 	})
@@ -232,9 +244,10 @@ func TestMatchExtraFilesGlob(t *testing.T) {
 		result := Equal(dir.Path(), manifest)()
 
 		assert.Assert(t, !result.Success())
-		expected := fmtExpected(`directory %s does not match expected:
-conf.yml
-  mode: expected -rwx------ got -rw-------
+		expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ conf.yml
+        mode: expected -rwx------ got -rw-------
 `, dir.Path())
 		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 	})
@@ -244,9 +257,10 @@ conf.yml
 		result := Equal(dir.Path(), manifest)()
 		assert.Assert(t, !result.Success())
 
-		expected := fmtExpected(`directory %s does not match expected:
+		expected := fmtExpected(`directory %s does not match expected (1 unexpected):
 /
-  conf.yml: unexpected file
+└── + conf.yml
+        unexpected file, does not match glob "*.go"
 `, dir.Path())
 		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 	})
@@ -256,18 +270,448 @@ conf.yml
 		result := Equal(dir.Path(), manifest)()
 		assert.Assert(t, !result.Success())
 
-		expected := fmtExpected(`directory %s does not match expected:
+		expected := fmtExpected(`directory %s does not match expected (3 unexpected):
+/
+├── + a.go
+│       unexpected file, does not match glob "[-x]"
+│       failed to match glob pattern: syntax error in pattern
+├── + conf.yml
+│       unexpected file, does not match glob "[-x]"
+│       failed to match glob pattern: syntax error in pattern
+└── + t.go
+        unexpected file, does not match glob "[-x]"
+        failed to match glob pattern: syntax error in pattern
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestMatchDirsWithGlob(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "expect mode does not match on windows")
+	dir := NewDir(t, t.Name(),
+		WithFile("file1", "content"),
+		WithDir("cache-1", WithFile("index", "one")),
+		WithDir("cache-2", WithFile("index", "two")),
+		WithDir("other", WithFile("index", "three")))
+	defer dir.Remove()
+
+	t.Run("matching globs", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "content"),
+			MatchDirsWithGlob("cache-*",
+				MatchFilesWithGlob("index", MatchAnyFileMode, MatchAnyFileContent)),
+			MatchDirsWithRegexp("oth.*", WithFile("index", "three")))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("structure does not match", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "content"),
+			MatchDirsWithGlob("cache-*", WithFile("index", "one")),
+			MatchFilesWithGlob("other", MatchAnyFileMode))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+
+		expected := fmtExpected(`directory %s does not match expected (1 unexpected, 1 changed):
+/
+├── + other
+│       unexpected directory, does not match directory glob "cache-*", glob "other"
+└── cache-2
+    └── ~ index
+            content:
+                --- expected
+                +++ actual
+                @@ -1 +1 @@
+                -one
+                +two
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+
+	t.Run("MatchExtraDirs", func(t *testing.T) {
+		manifest := Expected(t, WithFile("file1", "content"), MatchExtraDirs)
+		assert.Assert(t, Equal(dir.Path(), manifest))
+
+		manifest = Expected(t, MatchExtraDirs)
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (1 unexpected):
+/
+└── + file1
+        unexpected file, does not match directory glob "*"
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestMatchFilesWithRecursiveGlob(t *testing.T) {
+	dir := NewDir(t, t.Name(),
+		WithFile("main.go", ""),
+		WithDir("pkg",
+			WithFile("a.go", ""),
+			WithDir("sub", WithFile("b.go", ""), WithFile("notes.txt", ""))))
+	defer dir.Remove()
+
+	t.Run("matching glob", func(t *testing.T) {
+		manifest := Expected(t,
+			MatchFilesWithGlob("**/*.go", MatchAnyFileMode, MatchAnyFileContent),
+			MatchFilesWithGlob("pkg/sub/*.txt", MatchAnyFileMode, MatchAnyFileContent))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("unmatched file in subdirectory", func(t *testing.T) {
+		manifest := Expected(t, MatchFilesWithGlob("**/*.go", MatchAnyFileMode, MatchAnyFileContent))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+
+		expected := fmtExpected(`directory %s does not match expected (1 unexpected):
 /
-  a.go: unexpected file
-  conf.yml: unexpected file
-  t.go: unexpected file
-a.go
-  failed to match glob pattern: syntax error in pattern
-conf.yml
-  failed to match glob pattern: syntax error in pattern
-t.go
-  failed to match glob pattern: syntax error in pattern
+└── pkg
+    └── sub
+        └── + notes.txt
+                unexpected file, does not match glob "**/*.go"
 `, dir.Path())
 		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
 	})
 }
+
+func TestMatchFilesWithRegexp(t *testing.T) {
+	dir := NewDir(t, t.Name(),
+		WithFile("file-1.log", "log"),
+		WithFile("file-22.log", "log"),
+		WithFile("file-x.log", "log"),
+		WithFile("old-file-1.log.bak", "log"))
+	defer dir.Remove()
+
+	manifest := Expected(t,
+		MatchFilesWithRegexp(`file-\d+\.log`, MatchAnyFileMode, WithContent("log")))
+	result := Equal(dir.Path(), manifest)()
+	assert.Assert(t, !result.Success())
+
+	expected := fmtExpected(`directory %s does not match expected (2 unexpected):
+/
+├── + file-x.log
+│       unexpected file, does not match regexp "file-\\d+\\.log"
+└── + old-file-1.log.bak
+        unexpected file, does not match regexp "file-\\d+\\.log"
+`, dir.Path())
+	assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+
+	_, err := newRegexpPattern("(", nil)
+	assert.ErrorContains(t, err, `invalid regular expression "("`)
+}
+
+func TestEqualReportLimitsContentDiffs(t *testing.T) {
+	var dirOps, manifestOps []PathOp
+	for i := 0; i < maxContentDiffs+2; i++ {
+		name := fmt.Sprintf("file%02d", i)
+		dirOps = append(dirOps, WithFile(name, "actual"))
+		manifestOps = append(manifestOps, WithFile(name, "expected"))
+	}
+	dir := NewDir(t, t.Name(), dirOps...)
+	defer dir.Remove()
+
+	t.Run("limited", func(t *testing.T) {
+		result := Equal(dir.Path(), Expected(t, manifestOps...))()
+		assert.Assert(t, !result.Success())
+
+		msg := result.(cmpFailure).FailureMessage()
+		assert.Assert(t, strings.HasPrefix(msg,
+			fmt.Sprintf("directory %s does not match expected (12 changed):\n", dir.Path())))
+		assert.Equal(t, strings.Count(msg, "--- expected"), maxContentDiffs)
+		assert.Equal(t, strings.Count(msg, "content: diff omitted"), 2)
+		assert.Assert(t, strings.HasSuffix(msg, `
+2 content diffs were omitted, set GOTESTTOOLS_FS_FULL_REPORT=true to show all of them
+`))
+	})
+
+	t.Run("full report", func(t *testing.T) {
+		defer env.Patch(t, "GOTESTTOOLS_FS_FULL_REPORT", "true")()
+		result := Equal(dir.Path(), Expected(t, manifestOps...))()
+		assert.Assert(t, !result.Success())
+
+		msg := result.(cmpFailure).FailureMessage()
+		assert.Equal(t, strings.Count(msg, "--- expected"), maxContentDiffs+2)
+		assert.Assert(t, !strings.Contains(msg, "omitted"))
+	})
+
+	t.Run("report file", func(t *testing.T) {
+		report := NewFile(t, "report")
+		defer report.Remove()
+		defer env.Patch(t, "GOTESTTOOLS_FS_REPORT_FILE", report.Path())()
+
+		result := Equal(dir.Path(), Expected(t, manifestOps...))()
+		assert.Assert(t, !result.Success())
+
+		msg := result.(cmpFailure).FailureMessage()
+		assert.Assert(t, is.Contains(msg, "the full report was written to "+report.Path()))
+		content, err := ioutil.ReadFile(report.Path())
+		assert.NilError(t, err)
+		assert.Equal(t, strings.Count(string(content), "--- expected"), maxContentDiffs+2)
+	})
+}
+
+func TestEqualDirectory(t *testing.T) {
+	expected := NewDir(t, t.Name(),
+		WithMode(0755),
+		WithFile("file1", "content"),
+		WithDir("sub", WithFile("file2", "other")))
+	defer expected.Remove()
+
+	t.Run("matching directories", func(t *testing.T) {
+		dir := NewDir(t, t.Name(),
+			WithFile("file1", "content"),
+			WithDir("sub", WithFile("file2", "other")))
+		defer dir.Remove()
+
+		assert.Assert(t, EqualDirectory(dir.Path(), expected.Path()))
+	})
+
+	t.Run("different directories", func(t *testing.T) {
+		dir := NewDir(t, t.Name(),
+			WithFile("file1", "content"),
+			WithFile("extra", ""),
+			WithDir("sub"))
+		defer dir.Remove()
+
+		result := EqualDirectory(dir.Path(), expected.Path())()
+		assert.Assert(t, !result.Success())
+		expectedMsg := fmtExpected(`directory %s does not match %s (1 missing, 1 unexpected):
+/
+├── sub
+│   └── - file2
+│           expected file to exist
+└── + extra
+        unexpected file
+`, dir.Path(), expected.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expectedMsg)
+	})
+}
+
+func TestEqualWithHardlink(t *testing.T) {
+	dir := NewDir(t, t.Name(),
+		WithFile("file1", "content"),
+		WithDir("sub", WithFile("file2", "content")),
+		WithHardlink("link1", "file1"),
+		WithHardlink("link2", "sub/file2"))
+	defer dir.Remove()
+
+	t.Run("success", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "content"),
+			WithDir("sub", WithFile("file2", "content")),
+			WithHardlink("link1", "file1"),
+			WithHardlink("link2", "sub/file2"))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("not linked", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "content"),
+			WithDir("sub", WithFile("file2", "content")),
+			WithHardlink("link1", "sub/file2"),
+			WithHardlink("link2", "missing"))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (2 changed):
+/
+├── ~ link1
+│       hardlink: expected a link to sub/file2
+└── ~ link2
+        hardlink: expected target missing to be a file
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestEqualWithTimestamps(t *testing.T) {
+	stamp := time.Date(2011, 11, 11, 5, 55, 55, 0, time.UTC)
+	dir := NewDir(t, t.Name(),
+		WithFile("file1", "content", WithTimestamps(stamp, stamp)),
+		WithFile("file2", "content", WithTimestamps(stamp, stamp)))
+	defer dir.Remove()
+
+	t.Run("success", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "content", WithTimestamps(stamp, stamp)),
+			WithFile("file2", "content",
+				MatchTimestampsWithin(time.Minute),
+				WithTimestamps(stamp, stamp.Add(30*time.Second))))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("outside tolerance", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "content", WithTimestamps(stamp, stamp.Add(time.Second))),
+			WithFile("file2", "content"))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ file1
+        mtime: expected 2011-11-11T05:55:56Z got 2011-11-11T05:55:55Z
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestEqualWithFifoAndSocket(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "named pipes and unix sockets are not supported on windows")
+	dir := NewDir(t, t.Name(),
+		WithFifo("fifo1"),
+		WithSocket("socket1", WithMode(0600)),
+		WithFile("file1", ""))
+	defer dir.Remove()
+
+	t.Run("success", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFifo("fifo1"),
+			WithSocket("socket1", WithMode(0600)),
+			WithFile("file1", ""))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("mismatched types", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("fifo1", ""),
+			WithSocket("socket1"),
+			WithFifo("file1"))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (3 changed):
+/
+├── ~ fifo1
+│       type: expected file got fifo
+├── ~ file1
+│       type: expected fifo got file
+└── ~ socket1
+        mode: expected Srw-r--r-- got Srw-------
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestEqualWithXattr(t *testing.T) {
+	skip.If(t, runtime.GOOS != "linux", "extended attributes are only supported on linux")
+	dir := NewDir(t, t.Name(), WithFile("file1", ""))
+	defer dir.Remove()
+	if err := setXattr(dir.Join("file1"), "user.testing", "one"); err != nil {
+		t.Skipf("extended attributes are not supported by the filesystem: %v", err)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		manifest := Expected(t, WithFile("file1", "", WithXattr("user.testing", "one")))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		manifest := Expected(t,
+			WithFile("file1", "",
+				WithXattr("user.testing", "two"),
+				WithXattr("user.missing", "three")))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ file1
+        xattr user.missing: missing, expected value "three"
+        xattr user.testing: expected "two" got "one"
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestEqualWithIgnoreOwnership(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "ownership is not supported on windows")
+	dir := NewDir(t, t.Name(),
+		WithFile("file1", ""),
+		WithDir("sub", WithFile("file2", "")))
+	defer dir.Remove()
+
+	t.Run("ignored", func(t *testing.T) {
+		manifest := Expected(t,
+			IgnoreOwnership,
+			WithFile("file1", "", AsUser(12345, 12345), IgnoreOwnership),
+			WithDir("sub", WithFile("file2", "")))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("override with AsUser", func(t *testing.T) {
+		manifest := Expected(t,
+			IgnoreOwnership,
+			WithFile("file1", ""),
+			WithDir("sub", AsUser(12345, 12345), WithFile("file2", "")))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── ~ sub
+        uid: expected 12345 got %d
+        gid: expected 12345 got %d
+`, dir.Path(), os.Getuid(), os.Getgid())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestEqualWithModeMask(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+	dir := NewDir(t, t.Name(),
+		WithMode(0700),
+		WithFile("file1", "", WithMode(0640)),
+		WithDir("sub", WithMode(0750), WithFile("file2", "", WithMode(0604))))
+	defer dir.Remove()
+
+	t.Run("masked", func(t *testing.T) {
+		manifest := Expected(t,
+			ModeMask(0700),
+			WithFile("file1", "", WithMode(0600)),
+			WithDir("sub", WithMode(0700), WithFile("file2", "", WithMode(0600))))
+		assert.Assert(t, Equal(dir.Path(), manifest))
+	})
+
+	t.Run("override", func(t *testing.T) {
+		manifest := Expected(t,
+			ModeMask(0700),
+			WithFile("file1", "", WithMode(0600)),
+			WithDir("sub", WithMode(0700),
+				WithFile("file2", "", WithMode(0600), ModeMask(0777))))
+		result := Equal(dir.Path(), manifest)()
+		assert.Assert(t, !result.Success())
+		expected := fmtExpected(`directory %s does not match expected (1 changed):
+/
+└── sub
+    └── ~ file2
+            mode: expected -rw------- got -rw----r--
+`, dir.Path())
+		assert.Equal(t, result.(cmpFailure).FailureMessage(), expected)
+	})
+}
+
+func TestEqualWithUmask(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+	dir := NewDir(t, t.Name(),
+		WithFile("file1", "", WithMode(0644)),
+		WithSymlink("link", "file1"),
+		WithDir("sub", WithMode(0755), WithFile("file2", "", WithMode(0666))))
+	defer dir.Remove()
+
+	manifest := Expected(t,
+		WithUmask(0022),
+		WithFile("file1", "", WithMode(0666)),
+		WithSymlink("link", dir.Join("file1")),
+		WithDir("sub", WithMode(0777),
+			WithFile("file2", "", WithMode(0666), WithUmask(0))))
+	assert.Assert(t, Equal(dir.Path(), manifest))
+}
+
+func TestApplyUmask(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+	dir := NewDir(t, t.Name())
+	defer dir.Remove()
+	f, err := os.OpenFile(dir.Join("file1"), os.O_CREATE|os.O_WRONLY, 0666)
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	manifest := Expected(t, ApplyUmask, WithFile("file1", "", WithMode(0666)))
+	assert.Assert(t, Equal(dir.Path(), manifest))
+}
@@ -0,0 +1,295 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxContentDiffs is the number of content diffs included in a report, unless
+// the full report is enabled with GOTESTTOOLS_FS_FULL_REPORT.
+const maxContentDiffs = 10
+
+const (
+	envFullReport = "GOTESTTOOLS_FS_FULL_REPORT"
+	envReportFile = "GOTESTTOOLS_FS_REPORT_FILE"
+)
+
+const (
+	contentDiffPrefix = "content:\n"
+	contentPrefix     = "content: "
+)
+
+// formatReport formats the failures as a tree of the entries in the directory,
+// after a header line with a summary of the failures. Missing entries are
+// marked with -, unexpected entries with +, and entries with different
+// properties with ~. The entries in each directory are ordered by the most
+// severe failure they contain, missing before unexpected before changed, and
+// then by name.
+//
+// The number of content diffs in the report is limited to maxContentDiffs. The
+// limit is removed by setting GOTESTTOOLS_FS_FULL_REPORT=true. If
+// GOTESTTOOLS_FS_REPORT_FILE is set, the full report is appended to the file.
+func formatReport(header string, failures []failure) string {
+	root := newReportTree(failures)
+	header = fmt.Sprintf("%s (%s):\n", header, root.summary())
+
+	limit := maxContentDiffs
+	if fullReport, _ := strconv.ParseBool(os.Getenv(envFullReport)); fullReport {
+		limit = -1
+	}
+	r := newReportRenderer(limit)
+	r.render(root)
+
+	var notes []string
+	if r.omitted > 0 {
+		notes = append(notes, fmt.Sprintf(
+			"%d content diffs were omitted, set %s=true to show all of them",
+			r.omitted, envFullReport))
+	}
+	if filename := os.Getenv(envReportFile); filename != "" {
+		// the full report is only rendered again when the report is not
+		// already complete
+		full := r
+		if r.omitted > 0 {
+			full = newReportRenderer(-1)
+			full.render(root)
+		}
+		if err := appendReport(filename, header+full.buf.String()); err != nil {
+			notes = append(notes, fmt.Sprintf("failed to write the full report: %s", err))
+		} else {
+			notes = append(notes, "the full report was written to "+filename)
+		}
+	}
+	return header + r.buf.String() + formatNotes(notes)
+}
+
+func formatNotes(notes []string) string {
+	if len(notes) == 0 {
+		return ""
+	}
+	return "\n" + strings.Join(notes, "\n") + "\n"
+}
+
+func appendReport(filename, report string) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(report + "\n"); err != nil {
+		f.Close() // nolint: errcheck
+		return err
+	}
+	return f.Close()
+}
+
+// reportNode is an entry in the tree of failures. Entries without a failure
+// are the parent directories of entries with failures.
+type reportNode struct {
+	name     string
+	failure  *failure
+	children map[string]*reportNode
+	// severity is the severity of the most severe failure of the node, or of
+	// any of its descendants.
+	severity int
+}
+
+func newReportTree(failures []failure) *reportNode {
+	root := &reportNode{
+		name:     string(filepath.Separator),
+		children: make(map[string]*reportNode),
+	}
+	for i := range failures {
+		root.add(&failures[i])
+	}
+	root.setSeverity()
+	return root
+}
+
+// noFailureSeverity is the severity of a node without a failure, which is less
+// severe than any failure.
+const noFailureSeverity = 3
+
+func (n *reportNode) setSeverity() int {
+	n.severity = noFailureSeverity
+	if n.failure != nil {
+		n.severity = n.failure.kind.severity()
+	}
+	for _, child := range n.children {
+		if s := child.setSeverity(); s < n.severity {
+			n.severity = s
+		}
+	}
+	return n.severity
+}
+
+func (n *reportNode) add(f *failure) {
+	node := n
+	for _, name := range strings.Split(filepath.ToSlash(f.path), "/") {
+		if name == "" {
+			continue
+		}
+		child, ok := node.children[name]
+		if !ok {
+			child = &reportNode{name: name, children: make(map[string]*reportNode)}
+			node.children[name] = child
+		}
+		node = child
+	}
+	switch {
+	case node.failure == nil:
+		node.failure = &failure{path: f.path, kind: f.kind, problems: f.problems}
+	case f.kind != failureChanged:
+		// an unexpected entry may also have failed to match a pattern, the
+		// problem which explains the kind of failure is reported first.
+		node.failure.kind = f.kind
+		node.failure.problems = append(append([]problem{}, f.problems...), node.failure.problems...)
+	default:
+		node.failure.problems = append(node.failure.problems, f.problems...)
+	}
+}
+
+func (n *reportNode) sortedChildren() []*reportNode {
+	children := make([]*reportNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].severity != children[j].severity {
+			return children[i].severity < children[j].severity
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// summary returns the number of entries with each kind of failure.
+func (n *reportNode) summary() string {
+	counts := make(map[failureKind]int)
+	n.count(counts)
+
+	var parts []string
+	for _, kind := range []failureKind{failureMissing, failureExtra, failureChanged} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (n *reportNode) count(counts map[failureKind]int) {
+	if n.failure != nil {
+		counts[n.failure.kind]++
+	}
+	for _, child := range n.children {
+		child.count(counts)
+	}
+}
+
+func (k failureKind) String() string {
+	switch k {
+	case failureMissing:
+		return "missing"
+	case failureExtra:
+		return "unexpected"
+	default:
+		return "changed"
+	}
+}
+
+// severity returns the rank of the kind of failure in a report, a lower value
+// is more severe.
+func (k failureKind) severity() int {
+	switch k {
+	case failureMissing:
+		return 0
+	case failureExtra:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (k failureKind) marker() string {
+	switch k {
+	case failureMissing:
+		return "-"
+	case failureExtra:
+		return "+"
+	default:
+		return "~"
+	}
+}
+
+type reportRenderer struct {
+	buf bytes.Buffer
+	// maxContentDiffs is the number of content diffs to render, or -1 to render
+	// all of them.
+	maxContentDiffs int
+	contentDiffs    int
+	omitted         int
+}
+
+func newReportRenderer(maxContentDiffs int) *reportRenderer {
+	return &reportRenderer{maxContentDiffs: maxContentDiffs}
+}
+
+func (r *reportRenderer) render(root *reportNode) {
+	r.renderNode(root, "", "")
+}
+
+// renderNode writes the node after linePrefix, and its problems and children
+// after childPrefix.
+func (r *reportRenderer) renderNode(n *reportNode, linePrefix, childPrefix string) {
+	r.buf.WriteString(linePrefix)
+	if n.failure != nil {
+		r.buf.WriteString(n.failure.kind.marker() + " ")
+	}
+	r.buf.WriteString(n.name + "\n")
+
+	children := n.sortedChildren()
+	if n.failure != nil {
+		problemPrefix := childPrefix + "    "
+		if len(children) > 0 {
+			problemPrefix = childPrefix + "│   "
+		}
+		for _, p := range n.failure.problems {
+			r.renderProblem(p, problemPrefix)
+		}
+	}
+
+	for i, child := range children {
+		if i == len(children)-1 {
+			r.renderNode(child, childPrefix+"└── ", childPrefix+"    ")
+			continue
+		}
+		r.renderNode(child, childPrefix+"├── ", childPrefix+"│   ")
+	}
+}
+
+// isContentDiff returns true if the problem is a diff of the content of a file,
+// either from the comparison to the expected content, or from any content
+// matcher with a multi-line failure message, like ContentJSONEqual.
+func isContentDiff(text string) bool {
+	return strings.HasPrefix(text, contentDiffPrefix) ||
+		strings.HasPrefix(text, contentPrefix) && strings.Contains(text, "\n")
+}
+
+func (r *reportRenderer) renderProblem(p problem, prefix string) {
+	text := string(p)
+	if isContentDiff(text) {
+		if r.maxContentDiffs >= 0 && r.contentDiffs >= r.maxContentDiffs {
+			r.omitted++
+			// keep the first line, which describes the difference
+			text = strings.SplitN(text, "\n", 2)[0] + " diff omitted"
+		}
+		r.contentDiffs++
+	}
+	for _, line := range strings.Split(text, "\n") {
+		r.buf.WriteString(prefix + line + "\n")
+	}
+}
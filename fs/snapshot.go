@@ -0,0 +1,338 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// DirSnapshot stores the structure, properties, and content of a directory
+// tree at a point in time. Use Snapshot to create a DirSnapshot, and Changes or
+// ChangedOnly to compare it to the current state of the directory.
+type DirSnapshot struct {
+	path    string
+	entries map[string]dirEntry
+}
+
+// Snapshot reads the directory tree at path, including the content of every
+// file, and returns a DirSnapshot which can be compared to the directory after
+// it has been modified.
+func Snapshot(t assert.TestingT, path string) *DirSnapshot {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	entries, err := snapshotEntries(path)
+	assert.NilError(t, err)
+	return &DirSnapshot{path: path, entries: entries}
+}
+
+// snapshotEntries reads the directory at dir and returns all the entries in
+// the tree keyed by their slash separated path relative to dir. The content
+// of every file is read into memory.
+func snapshotEntries(dir string) (map[string]dirEntry, error) {
+	manifest, err := manifestFromDir(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]dirEntry)
+	err = flattenDirectory("", manifest.root, entries)
+	return entries, err
+}
+
+func flattenDirectory(prefix string, dir *directory, entries map[string]dirEntry) error {
+	var firstErr error
+	for name, entry := range dir.items {
+		entryPath := path.Join(prefix, name)
+		entries[entryPath] = entry
+		switch typed := entry.(type) {
+		case *directory:
+			if err := flattenDirectory(entryPath, typed, entries); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		case *file:
+			content, err := ioutil.ReadAll(typed.content)
+			typed.content.Close() // nolint: errcheck
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			typed.content = newBufferedContent(content)
+		}
+	}
+	return firstErr
+}
+
+// bufferedContent is the content of a file which has been read into memory.
+type bufferedContent struct {
+	*bytes.Reader
+	raw []byte
+}
+
+func newBufferedContent(raw []byte) *bufferedContent {
+	return &bufferedContent{Reader: bytes.NewReader(raw), raw: raw}
+}
+
+func (c *bufferedContent) Close() error {
+	return nil
+}
+
+func contentBytes(f *file) []byte {
+	if c, ok := f.content.(*bufferedContent); ok {
+		return c.raw
+	}
+	return nil
+}
+
+// ChangeKind identifies the type of change to an entry in a directory.
+type ChangeKind string
+
+const (
+	// ChangeCreated is an entry which did not exist in the snapshot
+	ChangeCreated ChangeKind = "created"
+	// ChangeDeleted is an entry which no longer exists
+	ChangeDeleted ChangeKind = "deleted"
+	// ChangeModified is an entry which exists in both, but has a different
+	// content, mode, owner, or modification time
+	ChangeModified ChangeKind = "modified"
+	// ChangeRenamed is a file or symlink which was deleted from one path and
+	// created at another path, without any other changes.
+	ChangeRenamed ChangeKind = "renamed"
+)
+
+// Change is a single change to an entry in a directory tree.
+type Change struct {
+	Kind ChangeKind
+	// Path is the slash separated path to the entry, relative to the root of
+	// the directory. For ChangeRenamed it is the new path.
+	Path string
+	// From is the old path when Kind is ChangeRenamed
+	From string
+	// Properties lists the properties which changed when Kind is
+	// ChangeModified. Properties may include content, mode, owner, mtime,
+	// xattrs, and target.
+	Properties []string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeRenamed:
+		return fmt.Sprintf("%s: %s from %s", c.Path, c.Kind, c.From)
+	case ChangeModified:
+		if len(c.Properties) > 0 {
+			return fmt.Sprintf("%s: %s %s", c.Path, c.Kind, strings.Join(c.Properties, ", "))
+		}
+	}
+	return fmt.Sprintf("%s: %s", c.Path, c.Kind)
+}
+
+// Created returns the Change expected when an entry is created at path.
+func Created(path string) Change {
+	return Change{Kind: ChangeCreated, Path: path}
+}
+
+// Deleted returns the Change expected when the entry at path is deleted.
+func Deleted(path string) Change {
+	return Change{Kind: ChangeDeleted, Path: path}
+}
+
+// Modified returns the Change expected when the entry at path is modified.
+// The expected Change matches any modified properties.
+func Modified(path string) Change {
+	return Change{Kind: ChangeModified, Path: path}
+}
+
+// Renamed returns the Change expected when the entry at from is moved to path.
+func Renamed(from, path string) Change {
+	return Change{Kind: ChangeRenamed, Path: path, From: from}
+}
+
+// Changes compares the directory at path to the snapshot and returns all the
+// changes, sorted by path. Changes to directories do not include their
+// modification time, because it changes whenever an entry in the directory is
+// created or deleted.
+func Changes(t assert.TestingT, snapshot *DirSnapshot, path string) []Change {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	changes, err := changesFromSnapshot(snapshot, path)
+	assert.NilError(t, err)
+	return changes
+}
+
+func changesFromSnapshot(snapshot *DirSnapshot, dir string) ([]Change, error) {
+	current, err := snapshotEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes, created, deleted []Change
+	for _, name := range sortedKeys(snapshot.entries) {
+		before := snapshot.entries[name]
+		after, ok := current[name]
+		switch {
+		case !ok:
+			deleted = append(deleted, Deleted(name))
+		case before.Type() != after.Type():
+			deleted = append(deleted, Deleted(name))
+			created = append(created, Created(name))
+		default:
+			if properties := modifiedProperties(before, after); len(properties) > 0 {
+				change := Modified(name)
+				change.Properties = properties
+				changes = append(changes, change)
+			}
+		}
+	}
+	for _, name := range sortedKeys(current) {
+		if _, ok := snapshot.entries[name]; !ok {
+			created = append(created, Created(name))
+		}
+	}
+
+	changes = append(changes, detectRenames(snapshot.entries, current, created, deleted)...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// nolint: gocyclo
+func modifiedProperties(before, after dirEntry) []string {
+	var properties []string
+	x, y := resourceOf(before), resourceOf(after)
+	switch typedX := before.(type) {
+	case *file:
+		if !bytes.Equal(contentBytes(typedX), contentBytes(after.(*file))) {
+			properties = append(properties, "content")
+		}
+	case *symlink:
+		if typedX.target != after.(*symlink).target {
+			properties = append(properties, "target")
+		}
+	}
+	if x.mode != y.mode {
+		properties = append(properties, "mode")
+	}
+	if x.uid != y.uid || x.gid != y.gid {
+		properties = append(properties, "owner")
+	}
+	if _, isDir := before.(*directory); !isDir && !x.mtime.Equal(y.mtime) {
+		properties = append(properties, "mtime")
+	}
+	if !reflect.DeepEqual(x.xattrs, y.xattrs) {
+		properties = append(properties, "xattrs")
+	}
+	return properties
+}
+
+func resourceOf(entry dirEntry) resource {
+	switch typed := entry.(type) {
+	case *file:
+		return typed.resource
+	case *symlink:
+		return typed.resource
+	case *special:
+		return typed.resource
+	case *directory:
+		return typed.resource
+	}
+	return resource{}
+}
+
+// detectRenames pairs each deleted file or symlink with a created entry of the
+// same type and the same content, and returns the renames, and all the created
+// and deleted changes which were not part of a rename.
+func detectRenames(before, after map[string]dirEntry, created, deleted []Change) []Change {
+	var changes []Change
+	renamedTo := make(map[string]bool)
+	for _, del := range deleted {
+		var rename *Change
+		for _, cre := range created {
+			if renamedTo[cre.Path] || !sameContent(before[del.Path], after[cre.Path]) {
+				continue
+			}
+			r := Renamed(del.Path, cre.Path)
+			rename = &r
+			renamedTo[cre.Path] = true
+			break
+		}
+		if rename != nil {
+			changes = append(changes, *rename)
+			continue
+		}
+		changes = append(changes, del)
+	}
+	for _, cre := range created {
+		if !renamedTo[cre.Path] {
+			changes = append(changes, cre)
+		}
+	}
+	return changes
+}
+
+func sameContent(x, y dirEntry) bool {
+	switch typedX := x.(type) {
+	case *file:
+		typedY, ok := y.(*file)
+		return ok && typedX.mode == typedY.mode &&
+			bytes.Equal(contentBytes(typedX), contentBytes(typedY))
+	case *symlink:
+		typedY, ok := y.(*symlink)
+		return ok && typedX.target == typedY.target
+	}
+	return false
+}
+
+// ChangedOnly compares the directory at path to the snapshot, and returns
+// success if the only changes are the expected changes. A ChangeModified
+// matches any change to the properties of the entry.
+//
+// ChangedOnly is a cmp.Comparison which can be used with assert.Assert().
+func ChangedOnly(snapshot *DirSnapshot, path string, expected ...Change) cmp.Comparison {
+	return func() cmp.Result {
+		actual, err := changesFromSnapshot(snapshot, path)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+
+		matched := make(map[int]bool)
+		var unexpected []Change
+		for _, change := range actual {
+			found := false
+			for i, exp := range expected {
+				if !matched[i] && change.matches(exp) {
+					matched[i], found = true, true
+					break
+				}
+			}
+			if !found {
+				unexpected = append(unexpected, change)
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		for _, change := range unexpected {
+			buf.WriteString("  unexpected change: " + change.String() + "\n")
+		}
+		for i, exp := range expected {
+			if !matched[i] {
+				buf.WriteString("  missing change: " + exp.String() + "\n")
+			}
+		}
+		if buf.Len() == 0 {
+			return cmp.ResultSuccess
+		}
+		msg := fmt.Sprintf("directory %s has different changes than expected:\n", path)
+		return cmp.ResultFailure(msg + buf.String())
+	}
+}
+
+func (c Change) matches(expected Change) bool {
+	return c.Kind == expected.Kind && c.Path == expected.Path && c.From == expected.From
+}
@@ -0,0 +1,84 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+)
+
+func TestChanges(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("unchanged", "same"),
+		fs.WithFile("modified", "before"),
+		fs.WithFile("deleted", "gone"),
+		fs.WithFile("moved", "moving content"),
+		fs.WithDir("sub",
+			fs.WithFile("chmod", "", fs.WithMode(0644))))
+	defer dir.Remove()
+
+	snapshot := fs.Snapshot(t, dir.Path())
+
+	assert.NilError(t, ioutil.WriteFile(dir.Join("modified"), []byte("after"), 0644))
+	assert.NilError(t, os.Remove(dir.Join("deleted")))
+	assert.NilError(t, os.Rename(dir.Join("moved"), dir.Join("sub/moved")))
+	assert.NilError(t, os.Chmod(dir.Join("sub/chmod"), 0600))
+	assert.NilError(t, os.Mkdir(dir.Join("created"), 0755))
+	stamp := time.Date(2011, 11, 11, 5, 55, 55, 0, time.UTC)
+	assert.NilError(t, os.Chtimes(dir.Join("unchanged"), stamp, stamp))
+	assert.NilError(t, os.Chtimes(dir.Join("modified"), stamp, stamp))
+
+	changes := fs.Changes(t, snapshot, dir.Path())
+	expected := []string{
+		"created: created",
+		"deleted: deleted",
+		"modified: modified content, mtime",
+		"sub/chmod: modified mode",
+		"sub/moved: renamed from moved",
+		"unchanged: modified mtime",
+	}
+	assert.DeepEqual(t, changeStrings(changes), expected)
+}
+
+func changeStrings(changes []fs.Change) []string {
+	result := make([]string, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, change.String())
+	}
+	return result
+}
+
+type failure interface {
+	FailureMessage() string
+}
+
+func TestChangedOnly(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("config", "a=b"),
+		fs.WithFile("log", ""))
+	defer dir.Remove()
+
+	snapshot := fs.Snapshot(t, dir.Path())
+	assert.NilError(t, ioutil.WriteFile(dir.Join("config"), []byte("a=c"), 0644))
+	assert.NilError(t, ioutil.WriteFile(dir.Join("new"), []byte(""), 0644))
+
+	t.Run("expected changes", func(t *testing.T) {
+		assert.Assert(t, fs.ChangedOnly(snapshot, dir.Path(),
+			fs.Modified("config"),
+			fs.Created("new")))
+	})
+
+	t.Run("unexpected changes", func(t *testing.T) {
+		result := fs.ChangedOnly(snapshot, dir.Path(),
+			fs.Modified("config"),
+			fs.Deleted("log"))()
+		assert.Assert(t, !result.Success())
+		expected := "directory " + dir.Path() + " has different changes than expected:\n" +
+			"  unexpected change: new: created\n" +
+			"  missing change: log: deleted\n"
+		assert.Equal(t, result.(failure).FailureMessage(), expected)
+	})
+}
@@ -0,0 +1,142 @@
+package fs
+
+import (
+	"os"
+	"sync"
+)
+
+// tolerance changes how the properties of an expected resource are compared to
+// the actual resource. Fields which are nil are inherited from the parent
+// directory when the manifest is compared.
+type tolerance struct {
+	ignoreOwnership *bool
+	modeMask        *os.FileMode
+	umask           *os.FileMode
+}
+
+func (t tolerance) inherit(parent tolerance) tolerance {
+	if t.ignoreOwnership == nil {
+		t.ignoreOwnership = parent.ignoreOwnership
+	}
+	if t.modeMask == nil {
+		t.modeMask = parent.modeMask
+	}
+	if t.umask == nil {
+		t.umask = parent.umask
+	}
+	return t
+}
+
+func (t tolerance) ignoresOwnership() bool {
+	return t.ignoreOwnership != nil && *t.ignoreOwnership
+}
+
+// modes returns the expected and actual modes with the umask and mode mask
+// applied.
+func (t tolerance) modes(expected, actual os.FileMode) (os.FileMode, os.FileMode) {
+	if t.umask != nil && expected&os.ModeSymlink == 0 {
+		expected &^= *t.umask & os.ModePerm
+	}
+	if t.modeMask != nil {
+		ignored := preservedModeBits &^ *t.modeMask
+		expected &^= ignored
+		actual &^= ignored
+	}
+	return expected, actual
+}
+
+// expectOwnership is used when the uid or gid of a resource is set
+// explicitly, so that the ownership is compared even if a parent directory
+// uses IgnoreOwnership.
+func (t *tolerance) expectOwnership() {
+	ignore := false
+	t.ignoreOwnership = &ignore
+}
+
+func manifestResourceOf(path Path) *resource {
+	switch m := path.(type) {
+	case *filePath:
+		return &m.file.resource
+	case *directoryPath:
+		return &m.directory.resource
+	case *specialPath:
+		return &m.special.resource
+	}
+	return nil
+}
+
+// IgnoreOwnership is a PathOp that updates a Manifest so that the uid and gid
+// of the resource at path are not compared. When used on a directory, the
+// ownership of all the files and directories it contains are also ignored.
+// Use IgnoreOwnership with Expected to ignore ownership for the entire
+// manifest.
+//
+// AsUser overrides IgnoreOwnership for the resource at path, and the files in
+// the directory at path.
+func IgnoreOwnership(path Path) error {
+	if r := manifestResourceOf(path); r != nil {
+		ignore := true
+		r.tolerance.ignoreOwnership = &ignore
+	}
+	return nil
+}
+
+// ModeMask is a PathOp that updates a Manifest so that only the permission bits
+// in mask are compared for the resource at path. The type of the resource is
+// always compared. For example, ModeMask(0700) only compares the permissions
+// of the owner. The setuid, setgid, and sticky bits are only compared if they
+// are included in mask.
+//
+// When used on a directory the mask is also used for all the files and
+// directories it contains, unless they set a different ModeMask.
+func ModeMask(mask os.FileMode) PathOp {
+	return func(path Path) error {
+		if r := manifestResourceOf(path); r != nil {
+			r.tolerance.modeMask = &mask
+		}
+		return nil
+	}
+}
+
+// WithUmask is a PathOp that updates a Manifest so that the permission bits in
+// umask are removed from the expected mode of the resource at path before it is
+// compared. The mode of symlinks is not changed.
+//
+// When used on a directory the umask is also used for all the files and
+// directories it contains, unless they set a different umask. Use WithUmask(0)
+// to compare the exact mode of an entry in a directory which uses a umask.
+func WithUmask(umask os.FileMode) PathOp {
+	return func(path Path) error {
+		if r := manifestResourceOf(path); r != nil {
+			r.tolerance.umask = &umask
+		}
+		return nil
+	}
+}
+
+// ApplyUmask is a PathOp that updates a Manifest to use the umask of the
+// current process with WithUmask. ApplyUmask allows a manifest to use the
+// modes passed to functions like os.OpenFile and os.Mkdir, instead of the modes
+// those functions create with the current umask. On windows the umask is 0.
+func ApplyUmask(path Path) error {
+	umask, err := processUmask()
+	if err != nil {
+		return err
+	}
+	return WithUmask(umask)(path)
+}
+
+var (
+	umaskOnce  sync.Once
+	umaskValue os.FileMode
+	umaskErr   error
+)
+
+// processUmask returns the umask of the current process. The umask is read
+// once, and cached, because reading it requires creating a file.
+func processUmask() (os.FileMode, error) {
+	umaskOnce.Do(func() {
+		umaskValue, umaskErr = readUmask()
+	})
+	return umaskValue, umaskErr
+}
@@ -0,0 +1,66 @@
+package fs
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// readXattrs returns the extended attributes of the file at path, or nil if the
+// file has no extended attributes, or the filesystem does not support them.
+func readXattrs(path string) (map[string]string, error) {
+	names, err := readXattrValue(func(buf []byte) (int, error) {
+		return syscall.Listxattr(path, buf)
+	})
+	switch {
+	case err == syscall.ENOTSUP:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case len(names) == 0:
+		return nil, nil
+	}
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := readXattrValue(func(buf []byte) (int, error) {
+			return syscall.Getxattr(path, string(name), buf)
+		})
+		if err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = string(value)
+	}
+	return xattrs, nil
+}
+
+// maxXattrRetries is the number of times readXattrValue retries when the value
+// grows between the call which returns its size and the call which reads it.
+const maxXattrRetries = 5
+
+// readXattrValue calls read with a nil buffer to get the size of the value, and
+// then again with a buffer of that size. If the value grew in between, read
+// returns ERANGE, and the size is read again.
+func readXattrValue(read func(buf []byte) (int, error)) ([]byte, error) {
+	for i := 0; ; i++ {
+		size, err := read(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		size, err = read(buf)
+		switch {
+		case err == syscall.ERANGE && i < maxXattrRetries:
+			continue
+		case err != nil:
+			return nil, err
+		}
+		return buf[:size], nil
+	}
+}
+
+func setXattr(path, name, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
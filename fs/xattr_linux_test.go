@@ -0,0 +1,29 @@
+package fs
+
+import (
+	"syscall"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReadXattrValue_RetriesWhenValueGrows(t *testing.T) {
+	size, calls := len("one"), 0
+	read := func(buf []byte) (int, error) {
+		calls++
+		switch {
+		case buf == nil:
+			return size, nil
+		case calls == 2:
+			// the value changed after its size was read
+			size = len("three")
+			return 0, syscall.ERANGE
+		}
+		return copy(buf, "three"), nil
+	}
+
+	value, err := readXattrValue(read)
+	assert.NilError(t, err)
+	assert.Equal(t, string(value), "three")
+	assert.Equal(t, calls, 4)
+}
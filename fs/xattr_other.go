@@ -0,0 +1,17 @@
+// +build !linux
+
+package fs
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+func readXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
+
+func setXattr(path, name, value string) error {
+	return errors.Errorf("extended attributes are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,20 @@
+/*
+
+Command gty-golden-review lists, applies, or discards the pending golden file
+changes written by `go test -test.update-golden -test.update-golden-mode=new`.
+
+	$ go get gotest.tools/v3/golden/cmd/gty-golden-review
+
+Usage:
+
+	gty-golden-review [OPTIONS] list|apply|discard [PATH...]
+
+Pending files are found by walking each PATH (defaults to the current
+directory) and looking for files with a .new suffix in testdata directories.
+A directory with a .new suffix is a pending golden directory written by
+golden.AssertDir. Applying it replaces the whole golden directory.
+
+See --help for full usage.
+
+*/
+package main
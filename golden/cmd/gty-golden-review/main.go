@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gotest.tools/v3/golden"
+	"gotest.tools/v3/internal/format"
+)
+
+type options struct {
+	action string
+	paths  []string
+	quiet  bool
+}
+
+func main() {
+	name := os.Args[0]
+	flags, opts := setupFlags(name)
+	handleExitError(name, flags.Parse(os.Args[1:]))
+	log.SetFlags(0)
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	opts.action, opts.paths = args[0], args[1:]
+	handleExitError(name, run(*opts, os.Stdout))
+}
+
+func setupFlags(name string) (*pflag.FlagSet, *options) {
+	opts := options{}
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false,
+		"only print the filenames, not the diff, when listing pending files")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s [OPTIONS] list|apply|discard [PATH...]
+
+List, apply, or discard the pending golden file changes written by
+-test.update-golden-mode=new.
+
+%s`, name, flags.FlagUsages())
+	}
+	return flags, &opts
+}
+
+func handleExitError(name string, err error) {
+	switch {
+	case err == nil:
+		return
+	case err == pflag.ErrHelp:
+		os.Exit(0)
+	default:
+		log.Println(name + ": Error: " + err.Error())
+		os.Exit(3)
+	}
+}
+
+func run(opts options, out io.Writer) error {
+	if len(opts.paths) == 0 {
+		opts.paths = []string{"."}
+	}
+	var pending []string
+	for _, path := range opts.paths {
+		found, err := findPending(path)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, found...)
+	}
+
+	var action func(out io.Writer, pending string, opts options) error
+	switch opts.action {
+	case "list":
+		action = list
+	case "apply":
+		action = apply
+	case "discard":
+		action = discard
+	default:
+		return errors.Errorf("unknown action %q, must be one of: list, apply, discard",
+			opts.action)
+	}
+	for _, path := range pending {
+		if err := action(out, path, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPending returns the files and directories with golden.PendingSuffix in
+// all the testdata directories under root. Pending directories are written by
+// golden.AssertDir, and are not searched for more pending files.
+func findPending(root string) ([]string, error) {
+	var pending []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		isPending := strings.HasSuffix(path, golden.PendingSuffix) && inTestdata(path)
+		switch {
+		case err != nil:
+			return err
+		case info.IsDir() && (info.Name() == ".git" || info.Name() == "vendor"):
+			return filepath.SkipDir
+		case info.IsDir() && isPending:
+			pending = append(pending, path)
+			return filepath.SkipDir
+		case info.IsDir():
+			return nil
+		}
+		if isPending {
+			pending = append(pending, path)
+		}
+		return nil
+	})
+	return pending, err
+}
+
+func inTestdata(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if part == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+func goldenPath(pending string) string {
+	return strings.TrimSuffix(pending, golden.PendingSuffix)
+}
+
+func list(out io.Writer, pending string, opts options) error {
+	fmt.Fprintln(out, goldenPath(pending))
+	if opts.quiet {
+		return nil
+	}
+	info, err := os.Stat(pending)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return diffFile(out, goldenPath(pending), pending)
+	}
+	names, err := dirFiles(goldenPath(pending), pending)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err := diffFile(out, filepath.Join(goldenPath(pending), name), filepath.Join(pending, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffFile writes the diff from the golden file to the pending file. A missing
+// file is compared as an empty file.
+func diffFile(out io.Writer, goldenFile, pendingFile string) error {
+	expected, err := ioutil.ReadFile(goldenFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	actual, err := ioutil.ReadFile(pendingFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fmt.Fprint(out, format.UnifiedDiff(format.DiffConfig{
+		A:    string(expected),
+		B:    string(actual),
+		From: goldenFile,
+		To:   pendingFile,
+	}))
+	return nil
+}
+
+// dirFiles returns the sorted relative paths of the files in any of the dirs.
+// A missing directory has no files.
+func dirFiles(dirs ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			switch {
+			case os.IsNotExist(err) && path == dir:
+				return filepath.SkipDir
+			case err != nil:
+				return err
+			case info.IsDir():
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			seen[rel] = true
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func apply(out io.Writer, pending string, _ options) error {
+	fmt.Fprintln(out, "updated "+goldenPath(pending))
+	info, err := os.Stat(pending)
+	if err != nil {
+		return err
+	}
+	// a pending directory replaces the whole golden directory
+	if info.IsDir() {
+		if err := os.RemoveAll(goldenPath(pending)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(pending, goldenPath(pending))
+}
+
+func discard(out io.Writer, pending string, _ options) error {
+	fmt.Fprintln(out, "discarded "+pending)
+	return os.RemoveAll(pending)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/golden"
+)
+
+func setupPendingDir(t *testing.T) *fs.Dir {
+	return fs.NewDir(t, t.Name(),
+		fs.WithDir("pkg",
+			fs.WithDir("testdata",
+				fs.WithFile("one.golden", "one\n"),
+				fs.WithFile("one.golden.new", "one\nmore\n"),
+				fs.WithFile("two.golden", "two\n")),
+			fs.WithFile("not-golden.new", "ignored")))
+}
+
+func TestRunList(t *testing.T) {
+	dir := setupPendingDir(t)
+	defer dir.Remove()
+
+	out := new(bytes.Buffer)
+	err := run(options{action: "list", paths: []string{dir.Path()}}, out)
+	assert.NilError(t, err)
+
+	golden := dir.Join("pkg/testdata/one.golden")
+	assert.Assert(t, cmp.Contains(out.String(), "--- "+golden+"\n"))
+	assert.Assert(t, cmp.Contains(out.String(), "+more\n"))
+
+	t.Run("quiet", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := run(options{action: "list", paths: []string{dir.Path()}, quiet: true}, out)
+		assert.NilError(t, err)
+		assert.Equal(t, out.String(), golden+"\n")
+	})
+}
+
+func TestRunApply(t *testing.T) {
+	dir := setupPendingDir(t)
+	defer dir.Remove()
+
+	out := new(bytes.Buffer)
+	err := run(options{action: "apply", paths: []string{dir.Path()}}, out)
+	assert.NilError(t, err)
+
+	expected := fs.Expected(t,
+		fs.WithDir("pkg",
+			fs.WithDir("testdata",
+				fs.WithFile("one.golden", "one\nmore\n"),
+				fs.WithFile("two.golden", "two\n")),
+			fs.WithFile("not-golden.new", "ignored")))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+}
+
+func TestRunDiscard(t *testing.T) {
+	dir := setupPendingDir(t)
+	defer dir.Remove()
+
+	out := new(bytes.Buffer)
+	err := run(options{action: "discard", paths: []string{dir.Path()}}, out)
+	assert.NilError(t, err)
+
+	expected := fs.Expected(t,
+		fs.WithDir("pkg",
+			fs.WithDir("testdata",
+				fs.WithFile("one.golden", "one\n"),
+				fs.WithFile("two.golden", "two\n")),
+			fs.WithFile("not-golden.new", "ignored")))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+}
+
+func TestRunUnknownAction(t *testing.T) {
+	err := run(options{action: "bogus", paths: []string{"."}}, new(bytes.Buffer))
+	assert.Error(t, err, `unknown action "bogus", must be one of: list, apply, discard`)
+}
+
+func setupPendingTree(t *testing.T) *fs.Dir {
+	return fs.NewDir(t, t.Name(),
+		fs.WithDir("testdata",
+			fs.WithDir("tree",
+				fs.WithFile("a", "a\n"),
+				fs.WithFile("removed", "removed\n")),
+			fs.WithDir("tree"+golden.PendingSuffix,
+				fs.WithFile("a", "a\nchanged\n"),
+				fs.WithDir("sub", fs.WithFile("added.golden.new", "added\n")))))
+}
+
+func TestRunPendingDir(t *testing.T) {
+	t.Run("list", func(t *testing.T) {
+		dir := setupPendingTree(t)
+		defer dir.Remove()
+
+		out := new(bytes.Buffer)
+		err := run(options{action: "list", paths: []string{dir.Path()}, quiet: true}, out)
+		assert.NilError(t, err)
+		assert.Equal(t, out.String(), dir.Join("testdata/tree")+"\n")
+
+		out = new(bytes.Buffer)
+		err = run(options{action: "list", paths: []string{dir.Path()}}, out)
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(out.String(), "+changed\n"))
+		assert.Assert(t, cmp.Contains(out.String(), "-removed\n"))
+		assert.Assert(t, cmp.Contains(out.String(), "+added\n"))
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		dir := setupPendingTree(t)
+		defer dir.Remove()
+
+		err := run(options{action: "apply", paths: []string{dir.Path()}}, new(bytes.Buffer))
+		assert.NilError(t, err)
+
+		expected := fs.Expected(t,
+			fs.WithDir("testdata",
+				fs.WithDir("tree",
+					fs.WithFile("a", "a\nchanged\n"),
+					fs.WithDir("sub", fs.WithFile("added.golden.new", "added\n")))))
+		assert.Assert(t, fs.Equal(dir.Path(), expected))
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		dir := setupPendingTree(t)
+		defer dir.Remove()
+
+		err := run(options{action: "discard", paths: []string{dir.Path()}}, new(bytes.Buffer))
+		assert.NilError(t, err)
+
+		expected := fs.Expected(t,
+			fs.WithDir("testdata",
+				fs.WithDir("tree",
+					fs.WithFile("a", "a\n"),
+					fs.WithFile("removed", "removed\n"))))
+		assert.Assert(t, fs.Equal(dir.Path(), expected))
+	})
+}
@@ -0,0 +1,318 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/fs"
+)
+
+// AssertDir compares the directory tree at actualDir to the golden directory
+// tree at dirname. Like other golden files, dirname is relative to ./testdata
+// unless it is an absolute path. All files, directories, and symlinks are
+// compared using fs.EqualDirectory, including their content and file mode.
+//
+// Running `go test pkgname -test.update-golden` will update the golden
+// directory tree to match actualDir. Files are added, updated, and removed, and
+// the file mode of every entry is preserved.
+//
+// This is equivalent to assert.Assert(t, Dir(actualDir, dirname))
+func AssertDir(t assert.TestingT, actualDir string, dirname string, msgAndArgs ...interface{}) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	assert.Assert(t, Dir(actualDir, dirname), msgAndArgs...)
+}
+
+// Dir compares the directory tree at actualDir to the golden directory tree at
+// dirname and returns success if they are equal.
+//
+// See AssertDir for details about updating the golden directory. The review
+// modes of -test.update-golden-mode are also supported. The "new" mode writes
+// the actual tree to dirname with PendingSuffix, and the "summary" mode reports
+// the number of entries which would be added, removed, and changed.
+func Dir(actualDir string, dirname string) cmp.Comparison {
+	return func() cmp.Result {
+		if err := validateUpdateMode(); err != nil {
+			return cmp.ResultFromError(err)
+		}
+		goldenDir := Path(dirname)
+		if *flagUpdate && !isReviewMode() {
+			if err := syncDir(actualDir, goldenDir); err != nil {
+				return cmp.ResultFromError(err)
+			}
+		}
+		if err := recordDirUsage(goldenDir); err != nil {
+			return cmp.ResultFromError(err)
+		}
+		result := fs.EqualDirectory(actualDir, goldenDir)()
+		switch {
+		case !isReviewMode():
+			return result
+		case result.Success():
+			if updateMode() == updateModeNew {
+				if err := os.RemoveAll(goldenDir + PendingSuffix); err != nil {
+					return cmp.ResultFromError(err)
+				}
+			}
+			return result
+		}
+		return reviewDir(actualDir, goldenDir)
+	}
+}
+
+// reviewDir is used in place of syncDir when one of the review modes is
+// enabled. The golden directory is never modified.
+func reviewDir(actualDir, goldenDir string) cmp.Result {
+	switch updateMode() {
+	case updateModeNew:
+		pending := goldenDir + PendingSuffix
+		if err := syncDir(actualDir, pending); err != nil {
+			return cmp.ResultFromError(err)
+		}
+		return cmp.ResultFailure(fmt.Sprintf(
+			"golden directory %s does not match, the new tree was written to %s",
+			goldenDir, pending))
+	case updateModeSummary:
+		added, removed, changed, err := dirChanges(actualDir, goldenDir)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		return cmp.ResultFailure(fmt.Sprintf(
+			"golden directory %s would change: %s added, %s removed, %s changed",
+			goldenDir, plural(added, "entry", "entries"), plural(removed, "entry", "entries"),
+			plural(changed, "entry", "entries")))
+	default:
+		return cmp.ResultSuccess
+	}
+}
+
+func recordDirUsage(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err) && path == dir:
+			return filepath.SkipDir
+		case err != nil:
+			return err
+		}
+		goldenUsage.record(path)
+		return nil
+	})
+}
+
+// syncDir updates the tree at dest so that it matches the tree at source.
+//
+// Directories are made writable while the tree is updated, and the mode of each
+// directory is applied after all of its children are written.
+func syncDir(source, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	var dirModes []dirMode
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			dirModes = append(dirModes, dirMode{path: target, mode: info.Mode().Perm()})
+		}
+		return syncEntry(path, target, info)
+	})
+	if err != nil {
+		return err
+	}
+	if err := removeExtraEntries(source, dest); err != nil {
+		return err
+	}
+	// Apply the modes in reverse, so that the children of a directory are
+	// updated before the directory becomes read-only.
+	for i := len(dirModes) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirModes[i].path, dirModes[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type dirMode struct {
+	path string
+	mode os.FileMode
+}
+
+// writableDirMode is the mode of a directory while its children are updated.
+const writableDirMode = 0700
+
+func syncEntry(source, dest string, info os.FileInfo) error {
+	existing, err := os.Lstat(dest)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case existing.Mode()&os.ModeType != info.Mode()&os.ModeType:
+		if err := removeAll(dest); err != nil {
+			return err
+		}
+	case existing.Mode()&os.ModeSymlink != 0:
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		if err := os.MkdirAll(dest, writableDirMode); err != nil {
+			return err
+		}
+		return os.Chmod(dest, info.Mode().Perm()|writableDirMode)
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dest)
+	default:
+		content, err := ioutil.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(dest, content); err != nil {
+			return err
+		}
+		return os.Chmod(dest, info.Mode().Perm())
+	}
+}
+
+// removeAll removes path and any children it contains, after making any
+// directories writable.
+func removeAll(path string) error {
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case info.IsDir():
+			return os.Chmod(path, writableDirMode)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+func removeExtraEntries(source, dest string) error {
+	var extra []string
+	err := filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(source, rel)); os.IsNotExist(err) {
+			extra = append(extra, path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range extra {
+		if err := removeAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dirChanges returns the number of entries which would be added, removed, and
+// changed by updating goldenDir to match actualDir. The children of an added or
+// removed directory are not counted.
+func dirChanges(actualDir, goldenDir string) (added, removed, changed int, err error) {
+	err = filepath.Walk(actualDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(actualDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		existing, err := os.Lstat(filepath.Join(goldenDir, rel))
+		switch {
+		case os.IsNotExist(err):
+			added++
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case err != nil:
+			return err
+		}
+		same, err := sameEntry(path, filepath.Join(goldenDir, rel), info, existing)
+		if !same {
+			changed++
+		}
+		return err
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	err = filepath.Walk(goldenDir, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err) && path == goldenDir:
+			return filepath.SkipDir
+		case err != nil:
+			return err
+		}
+		rel, err := filepath.Rel(goldenDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(actualDir, rel)); os.IsNotExist(err) {
+			removed++
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return added, removed, changed, err
+}
+
+func sameEntry(x, y string, xInfo, yInfo os.FileInfo) (bool, error) {
+	switch {
+	case xInfo.Mode()&os.ModeType != yInfo.Mode()&os.ModeType:
+		return false, nil
+	case xInfo.Mode()&os.ModeSymlink != 0:
+		xTarget, err := os.Readlink(x)
+		if err != nil {
+			return false, err
+		}
+		yTarget, err := os.Readlink(y)
+		return xTarget == yTarget, err
+	case xInfo.Mode().Perm() != yInfo.Mode().Perm():
+		return false, nil
+	case xInfo.IsDir():
+		return true, nil
+	}
+	xContent, err := ioutil.ReadFile(x)
+	if err != nil {
+		return false, err
+	}
+	yContent, err := ioutil.ReadFile(y)
+	return bytes.Equal(xContent, yContent), err
+}
@@ -0,0 +1,170 @@
+package golden
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+)
+
+func TestAssertDir(t *testing.T) {
+	goldenDir := fs.NewDir(t, t.Name(),
+		fs.WithFile("config", "key=value\n"),
+		fs.WithDir("bin", fs.WithFile("run", "#!/bin/sh\n", fs.WithMode(0755))))
+	defer goldenDir.Remove()
+
+	t.Run("matches", func(t *testing.T) {
+		actual := fs.NewDir(t, t.Name(),
+			fs.WithFile("config", "key=value\n"),
+			fs.WithDir("bin", fs.WithFile("run", "#!/bin/sh\n", fs.WithMode(0755))))
+		defer actual.Remove()
+
+		fakeT := new(fakeT)
+		AssertDir(fakeT, actual.Path(), goldenDir.Path())
+		assert.Assert(t, !fakeT.Failed)
+	})
+
+	t.Run("does not match", func(t *testing.T) {
+		actual := fs.NewDir(t, t.Name(),
+			fs.WithFile("config", "key=other\n"),
+			fs.WithDir("bin", fs.WithFile("run", "#!/bin/sh\n", fs.WithMode(0755))))
+		defer actual.Remove()
+
+		fakeT := new(fakeT)
+		AssertDir(fakeT, actual.Path(), goldenDir.Path())
+		assert.Assert(t, fakeT.Failed)
+	})
+}
+
+func TestAssertDir_UpdateGolden(t *testing.T) {
+	undo := setUpdateFlag()
+	defer undo()
+
+	goldenDir := fs.NewDir(t, t.Name(),
+		fs.WithFile("config", "key=value\n"),
+		fs.WithFile("removed", ""),
+		fs.WithDir("removed-dir", fs.WithFile("file", "")),
+		fs.WithDir("bin", fs.WithFile("run", "old")))
+	defer goldenDir.Remove()
+
+	actual := fs.NewDir(t, t.Name(),
+		fs.WithFile("config", "key=other\n", fs.WithMode(0600)),
+		fs.WithFile("added", "new file\n"),
+		fs.WithSymlink("link", "added"),
+		fs.WithDir("bin", fs.WithFile("run", "#!/bin/sh\n", fs.WithMode(0755))))
+	defer actual.Remove()
+
+	fakeT := new(fakeT)
+	AssertDir(fakeT, actual.Path(), goldenDir.Path())
+	assert.Assert(t, !fakeT.Failed)
+
+	undo()
+	assert.Assert(t, fs.EqualDirectory(actual.Path(), goldenDir.Path()))
+}
+
+func TestAssertDir_UpdateGoldenChangesEntryType(t *testing.T) {
+	undo := setUpdateFlag()
+	defer undo()
+
+	goldenDir := fs.NewDir(t, t.Name(),
+		fs.WithFile("link", "was a file"),
+		fs.WithSymlink("file", "link"),
+		fs.WithDir("dir", fs.WithFile("file", "")))
+	defer goldenDir.Remove()
+
+	actual := fs.NewDir(t, t.Name(),
+		fs.WithFile("target", ""),
+		fs.WithSymlink("link", "target"),
+		fs.WithFile("file", "was a symlink"),
+		fs.WithFile("dir", "was a directory"))
+	defer actual.Remove()
+
+	fakeT := new(fakeT)
+	AssertDir(fakeT, actual.Path(), goldenDir.Path())
+	assert.Assert(t, !fakeT.Failed)
+
+	undo()
+	assert.Assert(t, fs.EqualDirectory(actual.Path(), goldenDir.Path()))
+}
+
+func TestAssertDir_UpdateGoldenReadOnlyDir(t *testing.T) {
+	undo := setUpdateFlag()
+	defer undo()
+
+	goldenDir := fs.NewDir(t, t.Name())
+	defer goldenDir.Remove()
+	defer os.Chmod(goldenDir.Join("readonly"), 0755) // nolint: errcheck
+
+	for _, content := range []string{"first", "second"} {
+		actual := fs.NewDir(t, t.Name(),
+			fs.WithDir("readonly",
+				fs.WithMode(0555),
+				fs.WithFile("file", content, fs.WithMode(0444))))
+		defer actual.Remove()
+		defer os.Chmod(actual.Join("readonly"), 0755) // nolint: errcheck
+
+		fakeT := new(fakeT)
+		AssertDir(fakeT, actual.Path(), goldenDir.Path())
+		assert.Assert(t, !fakeT.Failed)
+		assert.Assert(t, fs.EqualDirectory(actual.Path(), goldenDir.Path()))
+	}
+}
+
+func TestDir_UpdateModeNew(t *testing.T) {
+	undo := setUpdateMode("new")
+	defer undo()
+
+	goldenDir := fs.NewDir(t, t.Name(), fs.WithFile("config", "key=value\n"))
+	defer goldenDir.Remove()
+	pending := goldenDir.Path() + PendingSuffix
+	defer os.RemoveAll(pending)
+
+	actual := fs.NewDir(t, t.Name(), fs.WithFile("config", "key=other\n"))
+	defer actual.Remove()
+
+	result := Dir(actual.Path(), goldenDir.Path())()
+	assert.Assert(t, !result.Success())
+	assert.Equal(t, result.(failure).FailureMessage(),
+		"golden directory "+goldenDir.Path()+" does not match, the new tree was written to "+pending)
+	assert.Assert(t, fs.Equal(goldenDir.Path(), fs.Expected(t,
+		fs.WithMode(0700),
+		fs.WithFile("config", "key=value\n"))))
+	assert.Assert(t, fs.EqualDirectory(actual.Path(), pending))
+
+	t.Run("match removes pending tree", func(t *testing.T) {
+		result := Dir(goldenDir.Path(), goldenDir.Path())()
+		assert.Assert(t, result.Success())
+		_, err := os.Stat(pending)
+		assert.Assert(t, os.IsNotExist(err))
+	})
+}
+
+func TestDir_UpdateModeSummary(t *testing.T) {
+	undo := setUpdateMode("summary")
+	defer undo()
+
+	goldenDir := fs.NewDir(t, t.Name(),
+		fs.WithFile("config", "key=value\n"),
+		fs.WithFile("same", ""),
+		fs.WithDir("removed", fs.WithFile("a", ""), fs.WithFile("b", "")))
+	defer goldenDir.Remove()
+
+	actual := fs.NewDir(t, t.Name(),
+		fs.WithFile("config", "key=other\n"),
+		fs.WithFile("same", ""),
+		fs.WithFile("added", ""),
+		fs.WithFile("other", ""))
+	defer actual.Remove()
+
+	result := Dir(actual.Path(), goldenDir.Path())()
+	assert.Assert(t, !result.Success())
+	assert.Equal(t, result.(failure).FailureMessage(),
+		"golden directory "+goldenDir.Path()+
+			" would change: 2 entries added, 1 entry removed, 1 entry changed")
+	assert.Assert(t, fs.Equal(goldenDir.Path(), fs.Expected(t,
+		fs.WithMode(0700),
+		fs.WithFile("config", "key=value\n"),
+		fs.WithFile("same", ""),
+		fs.WithDir("removed", fs.WithFile("a", ""), fs.WithFile("b", "")))))
+}
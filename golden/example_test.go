@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
 	"gotest.tools/v3/golden"
 )
 
@@ -20,3 +21,38 @@ func ExampleString() {
 func ExampleAssertBytes() {
 	golden.AssertBytes(t, []byte("foo"), "foo-content.golden")
 }
+
+func ExampleAssertWith() {
+	output := "started 2020-05-01T15:04:05Z, took 1.5s"
+	golden.AssertWith(t, output, "output.golden",
+		golden.Normalize(golden.ScrubRFC3339Times, golden.ScrubDurations))
+}
+
+func ExampleAssertJSON() {
+	config := map[string]interface{}{"name": "example", "replicas": 3}
+	golden.AssertJSON(t, config, "config.golden.json")
+}
+
+func ExampleInline() {
+	golden.Inline(t, "some output", "some output")
+}
+
+func ExampleAssertT() {
+	golden.AssertT(t, "foo")
+	golden.AssertT(t, "{}", golden.WithSuffix("-config"), golden.WithExtension(".json"))
+}
+
+func ExampleAssertDir() {
+	dir := fs.NewDir(t, "generated")
+	defer dir.Remove()
+
+	golden.AssertDir(t, dir.Path(), "expected-tree")
+}
+
+func ExampleAssertTemplate() {
+	dir := fs.NewDir(t, "config")
+	defer dir.Remove()
+
+	output := "wrote config to " + dir.Path()
+	golden.AssertTemplate(t, output, "config.golden", map[string]string{"Dir": dir.Path()})
+}
@@ -4,6 +4,20 @@ Golden files are files in the ./testdata/ subdirectory of the package under test
 Golden files can be automatically updated to match new values by running
 `go test pkgname -test.update-golden`. To ensure the update is correct
 compare the diff of the old expected value to the new expected value.
+
+The -test.update-golden-mode flag changes how golden files are updated. The
+default mode, "write", overwrites the golden file. The "new" mode writes the
+proposed content to a file with a .new suffix next to the golden file, and the
+"summary" mode only reports the number of lines that would change. Both review
+modes leave the original golden files untouched, and fail the comparison. The
+default mode may also be set with GOTESTTOOLS_GOLDEN_UPDATE_MODE. Pending .new
+files can be applied or discarded with the gty-golden-review command.
+
+Golden files are written atomically, and keep their existing file mode. When
+two tests update the same golden file with different values the comparison
+fails, because the result would depend on the order the tests run in. This is
+only detected for tests in the same test binary, tests of different packages
+run in separate processes and are not checked.
 */
 package golden // import "gotest.tools/v3/golden"
 
@@ -18,10 +32,25 @@ import (
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/internal/format"
+	"gotest.tools/v3/internal/goldenhook"
 )
 
 var flagUpdate = flag.Bool("test.update-golden", false, "update golden file")
 
+var flagUpdateMode = flag.String("test.update-golden-mode",
+	os.Getenv("GOTESTTOOLS_GOLDEN_UPDATE_MODE"),
+	"how golden files are updated: write, new, or summary")
+
+const (
+	updateModeWrite   = "write"
+	updateModeNew     = "new"
+	updateModeSummary = "summary"
+)
+
+// PendingSuffix is the suffix added to the golden filename when the proposed
+// content is written by the "new" update mode.
+const PendingSuffix = ".new"
+
 type helperT interface {
 	Helper()
 }
@@ -44,11 +73,48 @@ func FlagUpdate() bool {
 	return *flagUpdate
 }
 
+// Settings are used to configure the behaviour of AssertWith, StringWith, and
+// AssertT
+type Settings struct {
+	// Normalizers are applied, in order, to the actual value. If
+	// NormalizeCRLFToLF is true, carriage returns are removed before any
+	// Normalizers are applied.
+	Normalizers []Normalizer
+	// Suffix is appended to the filename derived from the test name by AssertT.
+	Suffix string
+	// Extension is the extension of the golden file used by AssertT. Defaults
+	// to .golden.
+	Extension string
+
+	testName   string
+	msgAndArgs []interface{}
+}
+
+// SettingOp is a function which accepts and modifies Settings
+type SettingOp func(settings *Settings)
+
+func newSettings(ops []SettingOp) *Settings {
+	settings := &Settings{}
+	for _, op := range ops {
+		op(settings)
+	}
+	return settings
+}
+
+// WithMessage adds msgAndArgs to the failure message of AssertWith and
+// AssertT. See assert.Assert for the format of msgAndArgs.
+func WithMessage(msgAndArgs ...interface{}) SettingOp {
+	return func(settings *Settings) {
+		settings.msgAndArgs = msgAndArgs
+	}
+}
+
 // Open opens the file in ./testdata
 func Open(t assert.TestingT, filename string) *os.File {
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
+	goldenUsage.record(Path(filename))
 	f, err := os.Open(Path(filename))
 	assert.NilError(t, err)
 	return f
@@ -59,17 +125,33 @@ func Get(t assert.TestingT, filename string) []byte {
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
+	goldenUsage.record(Path(filename))
 	expected, err := ioutil.ReadFile(Path(filename))
 	assert.NilError(t, err)
 	return expected
 }
 
-// Path returns the full path to a file in ./testdata
+// Path returns the full path to a file in ./testdata.
+//
+// If a variant of the golden file exists for the current operating system or Go
+// version, the path to the most specific variant is returned. A variant adds the
+// value of runtime.GOOS, a Go version, or both, before the file extension. For
+// example, with go1.21 on linux the path for out.golden is the first of these
+// files which exists:
+//
+//   out.linux.go1.21.golden
+//   out.linux.golden
+//   out.go1.21.golden
+//   out.golden
+//
+// A Go version variant is used when the runtime version is the same or newer
+// than the version in the filename. When there are multiple version variants,
+// the one with the highest version is used.
 func Path(filename string) string {
 	if filepath.IsAbs(filename) {
-		return filename
+		return resolveVariant(filename)
 	}
-	return filepath.Join("testdata", filename)
+	return resolveVariant(filepath.Join("testdata", filename))
 }
 
 func removeCarriageReturn(in []byte) []byte {
@@ -89,7 +171,8 @@ func Assert(t assert.TestingT, actual string, filename string, msgAndArgs ...int
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
-	assert.Assert(t, String(actual, filename), msgAndArgs...)
+	settings := &Settings{testName: testName(t)}
+	assert.Assert(t, compareString(actual, filename, settings), msgAndArgs...)
 }
 
 // String compares actual to the contents of filename and returns success
@@ -103,9 +186,13 @@ func Assert(t assert.TestingT, actual string, filename string, msgAndArgs ...int
 // normalized version will be written to the file. This allows Windows to use
 // the same golden files as other operating systems.
 func String(actual string, filename string) cmp.Comparison {
+	return compareString(actual, filename, &Settings{})
+}
+
+func compareString(actual string, filename string, settings *Settings) cmp.Comparison {
 	return func() cmp.Result {
-		actualBytes := removeCarriageReturn([]byte(actual))
-		result, expected := compare(actualBytes, filename)
+		actualBytes := []byte(settings.normalize(actual))
+		result, expected := compare(actualBytes, filename, settings.testName)
 		if result != nil {
 			return result
 		}
@@ -141,7 +228,7 @@ func AssertBytes(
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
-	assert.Assert(t, Bytes(actual, filename), msgAndArgs...)
+	assert.Assert(t, compareBytes(actual, filename, testName(t)), msgAndArgs...)
 }
 
 // Bytes compares actual to the contents of filename and returns success
@@ -150,8 +237,12 @@ func AssertBytes(
 // Running `go test pkgname -test.update-golden` will write the value of actual
 // to the golden file.
 func Bytes(actual []byte, filename string) cmp.Comparison {
+	return compareBytes(actual, filename, "")
+}
+
+func compareBytes(actual []byte, filename string, testName string) cmp.Comparison {
 	return func() cmp.Result {
-		result, expected := compare(actual, filename)
+		result, expected := compare(actual, filename, testName)
 		if result != nil {
 			return result
 		}
@@ -160,28 +251,123 @@ func Bytes(actual []byte, filename string) cmp.Comparison {
 	}
 }
 
-func compare(actual []byte, filename string) (cmp.Result, []byte) {
+// compare actual to the contents of filename. testName is used to identify the
+// test in the error message if two tests update the same golden file with
+// different values.
+func compare(actual []byte, filename string, testName string) (cmp.Result, []byte) {
+	if err := validateUpdateMode(); err != nil {
+		return cmp.ResultFromError(err), nil
+	}
+	goldenUsage.record(Path(filename))
+	if *flagUpdate && !isReviewMode() {
+		if err := goldenUpdates.record(Path(filename), actual, testName); err != nil {
+			return cmp.ResultFromError(err), nil
+		}
+	}
 	if err := update(filename, actual); err != nil {
 		return cmp.ResultFromError(err), nil
 	}
 	expected, err := ioutil.ReadFile(Path(filename))
-	if err != nil {
+	switch {
+	case os.IsNotExist(err) && isReviewMode():
+	case err != nil:
 		return cmp.ResultFromError(err), nil
 	}
 	if bytes.Equal(expected, actual) {
+		if isReviewMode() && updateMode() == updateModeNew {
+			if err := removePending(filename); err != nil {
+				return cmp.ResultFromError(err), nil
+			}
+		}
 		return cmp.ResultSuccess, nil
 	}
+	if isReviewMode() {
+		return review(filename, expected, actual), nil
+	}
 	return nil, expected
 }
 
+func init() {
+	goldenhook.Compare = func(actual []byte, filename string) (cmp.Result, []byte) {
+		return compare(actual, filename, "")
+	}
+	goldenhook.Path = Path
+}
+
 func update(filename string, actual []byte) error {
+	if !*flagUpdate || isReviewMode() {
+		return nil
+	}
 	if dir := filepath.Dir(filename); dir != "." {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return err
 		}
 	}
-	if *flagUpdate {
-		return ioutil.WriteFile(Path(filename), actual, 0644)
+	return writeFileAtomic(Path(filename), actual)
+}
+
+func updateMode() string {
+	if *flagUpdateMode == "" {
+		return updateModeWrite
+	}
+	return *flagUpdateMode
+}
+
+// validateUpdateMode returns an error if -test.update-golden-mode is not one
+// of the supported modes. The mode is only checked when updating is enabled.
+func validateUpdateMode() error {
+	if !*flagUpdate {
+		return nil
+	}
+	switch mode := updateMode(); mode {
+	case updateModeWrite, updateModeNew, updateModeSummary:
+		return nil
+	default:
+		return fmt.Errorf("invalid -test.update-golden-mode %q, must be one of: %s, %s, %s",
+			mode, updateModeWrite, updateModeNew, updateModeSummary)
+	}
+}
+
+func isReviewMode() bool {
+	return *flagUpdate && updateMode() != updateModeWrite
+}
+
+// review is used in place of update when one of the review modes is enabled.
+// The golden file is never modified.
+func review(filename string, expected, actual []byte) cmp.Result {
+	switch updateMode() {
+	case updateModeNew:
+		pending := Path(filename) + PendingSuffix
+		if err := writeFileAtomic(pending, actual); err != nil {
+			return cmp.ResultFromError(err)
+		}
+		return cmp.ResultFailure(fmt.Sprintf(
+			"golden file %s does not match, the new value was written to %s",
+			Path(filename), pending))
+	case updateModeSummary:
+		added, removed := format.DiffStat(string(expected), string(actual))
+		return cmp.ResultFailure(fmt.Sprintf(
+			"golden file %s would change: %s added, %s removed",
+			Path(filename), plural(added, "line", "lines"), plural(removed, "line", "lines")))
+	default:
+		return cmp.ResultSuccess
+	}
+}
+
+// removePending removes the pending file written by a previous run in the
+// "new" mode, once the golden file matches again.
+func removePending(filename string) error {
+	err := os.Remove(Path(filename) + PendingSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// plural returns n followed by singular or plural, depending on n.
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
 	}
-	return nil
+	return fmt.Sprintf("%d %s", n, plural)
 }
@@ -1,6 +1,7 @@
 package golden
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -13,9 +14,11 @@ import (
 
 type fakeT struct {
 	Failed bool
+	Logs   []string
 }
 
-func (t *fakeT) Log(...interface{}) {
+func (t *fakeT) Log(args ...interface{}) {
+	t.Logs = append(t.Logs, fmt.Sprint(args...))
 }
 
 func (t *fakeT) FailNow() {
@@ -241,3 +244,142 @@ func TestUpdate_CreatesPathsAndFile(t *testing.T) {
 		})
 	})
 }
+
+func setUpdateMode(mode string) func() {
+	undoFlag := setUpdateFlag()
+	oldMode := *flagUpdateMode
+	*flagUpdateMode = mode
+	return func() {
+		*flagUpdateMode = oldMode
+		undoFlag()
+	}
+}
+
+func TestUpdateModeNew(t *testing.T) {
+	undo := setUpdateMode("new")
+	defer undo()
+	filename, clean := setupGoldenFile(t, "content\n")
+	defer clean()
+	pending := Path(filename) + PendingSuffix
+	defer os.Remove(pending)
+
+	t.Run("match does not write pending file", func(t *testing.T) {
+		result := String("content\n", filename)()
+		assert.Assert(t, result.Success())
+		_, err := os.Stat(pending)
+		assert.Assert(t, os.IsNotExist(err))
+	})
+
+	t.Run("mismatch writes pending file", func(t *testing.T) {
+		result := String("new content\n", filename)()
+		assert.Assert(t, !result.Success())
+		assert.Equal(t, result.(failure).FailureMessage(),
+			"golden file "+Path(filename)+" does not match, the new value was written to "+pending)
+
+		assert.Equal(t, string(Get(t, filename)), "content\n")
+		raw, err := ioutil.ReadFile(pending)
+		assert.NilError(t, err)
+		assert.Equal(t, string(raw), "new content\n")
+	})
+
+	t.Run("match removes stale pending file", func(t *testing.T) {
+		result := String("content\n", filename)()
+		assert.Assert(t, result.Success())
+		_, err := os.Stat(pending)
+		assert.Assert(t, os.IsNotExist(err))
+	})
+}
+
+func TestUpdateModeNewWithoutUpdateFlag(t *testing.T) {
+	oldMode := *flagUpdateMode
+	*flagUpdateMode = "new"
+	defer func() { *flagUpdateMode = oldMode }()
+	filename, clean := setupGoldenFile(t, "content\n")
+	defer clean()
+	pending := Path(filename) + PendingSuffix
+	assert.NilError(t, ioutil.WriteFile(pending, []byte("new content\n"), 0644))
+	defer os.Remove(pending)
+
+	assert.Assert(t, String("content\n", filename))
+	_, err := os.Stat(pending)
+	assert.NilError(t, err)
+}
+
+func TestUpdateModeSummary(t *testing.T) {
+	undo := setUpdateMode("summary")
+	defer undo()
+	filename, clean := setupGoldenFile(t, "one\ntwo\nthree\n")
+	defer clean()
+
+	result := String("one\n2\nthree\nfour\n", filename)()
+	assert.Assert(t, !result.Success())
+	assert.Equal(t, result.(failure).FailureMessage(),
+		"golden file "+Path(filename)+" would change: 2 lines added, 1 line removed")
+	assert.Equal(t, string(Get(t, filename)), "one\ntwo\nthree\n")
+}
+
+func TestUpdateModeInvalid(t *testing.T) {
+	undo := setUpdateMode("bogus")
+	defer undo()
+	filename, clean := setupGoldenFile(t, "content")
+	defer clean()
+
+	t.Run("mismatch", func(t *testing.T) {
+		result := String("other", filename)()
+		assert.Assert(t, !result.Success())
+		assert.Assert(t, cmp.Contains(result.(failure).FailureMessage(),
+			`invalid -test.update-golden-mode "bogus"`))
+	})
+
+	t.Run("match", func(t *testing.T) {
+		result := String("content", filename)()
+		assert.Assert(t, !result.Success())
+		assert.Assert(t, cmp.Contains(result.(failure).FailureMessage(),
+			`invalid -test.update-golden-mode "bogus"`))
+		assert.Equal(t, string(Get(t, filename)), "content")
+	})
+}
+
+func TestUpdateModeSummaryDoesNotCreateDirs(t *testing.T) {
+	undo := setUpdateMode("summary")
+	defer undo()
+	dir := fs.NewDir(t, t.Name())
+	defer dir.Remove()
+
+	filename := dir.Join("one/two/filename")
+	result := String("content", filename)()
+	assert.Assert(t, !result.Success())
+	_, err := os.Stat(dir.Join("one"))
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestContentGolden_UpdateGolden(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "content\n")
+	defer clean()
+	dir := fs.NewDir(t, t.Name(), fs.WithFile("out", "new content\n"))
+	defer dir.Remove()
+	check := fs.EqualFile(dir.Join("out"), fs.ContentGolden(filename))
+
+	t.Run("review mode", func(t *testing.T) {
+		undo := setUpdateMode("new")
+		defer undo()
+		pending := Path(filename) + PendingSuffix
+		defer os.Remove(pending)
+
+		result := check()
+		assert.Assert(t, !result.Success())
+		assert.Equal(t, string(Get(t, filename)), "content\n")
+		raw, err := ioutil.ReadFile(pending)
+		assert.NilError(t, err)
+		assert.Equal(t, string(raw), "new content\n")
+	})
+
+	t.Run("write mode", func(t *testing.T) {
+		undo := setUpdateFlag()
+		defer undo()
+
+		assert.Assert(t, check)
+		assert.Equal(t, string(Get(t, filename)), "new content\n")
+		assert.Assert(t, goldenUsage.used(Path(filename)))
+	})
+}
@@ -0,0 +1,144 @@
+package golden
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/internal/format"
+	"gotest.tools/v3/internal/source"
+)
+
+// Inline compares actual to expected, where expected is a string literal in the
+// source of the test. Inline is intended for small expected values which are
+// easier to read when they are next to the assertion.
+//
+// Running `go test pkgname -test.update-golden` will replace the expected
+// string literal in the source file with the value of actual. Raw string
+// literals are preserved when possible, and the file is formatted with gofmt.
+// Inline must be called directly from the test, and expected must be a string
+// literal, otherwise the source can not be updated. A call in a loop, or in a
+// table-driven test, has a single expected literal for all the values of
+// actual, so the test fails when the update would give it more than one value,
+// and the expected literal is left unchanged. The source is updated when the
+// test ends, or immediately when t does not support Cleanup.
+//
+// Any \r\n substrings in actual are converted to a single \n character before
+// comparing it to expected, when NormalizeCRLFToLF is true.
+func Inline(t assert.TestingT, actual string, expected string, msgAndArgs ...interface{}) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	actual = string(removeCarriageReturn([]byte(actual)))
+	if *flagUpdate && !isReviewMode() {
+		const stackIndex = 1 // Inline()
+		site, err := source.Caller(stackIndex)
+		if err == nil {
+			err = inlineCallSites.record(t, site, actual, expected)
+		}
+		assert.NilError(t, err, "failed to update the expected value")
+		return
+	}
+	if actual == expected {
+		return
+	}
+
+	diff := format.UnifiedDiff(format.DiffConfig{
+		A:    expected,
+		B:    actual,
+		From: "expected",
+		To:   "actual",
+	})
+	msg := fmt.Sprintf("\n%s%s", diff, `
+
+You can run 'go test . -test.update-golden' to automatically update the expected `+
+		`value in the source file.
+`)
+	assert.Assert(t, cmp.Comparison(func() cmp.Result {
+		return cmp.ResultFailure(msg)
+	}), msgAndArgs...)
+}
+
+// expectedArgIndex is the position of the expected argument in
+// Inline(t, actual, expected)
+const expectedArgIndex = 2
+
+// inlineCall is a call to Inline from a call site in the source of a test.
+type inlineCall struct {
+	actual string
+	// conflict is true if the call site was used with different values
+	conflict bool
+	// original is the source of the literal replaced by the update, or an
+	// empty string if the source was not updated.
+	original string
+}
+
+type callSites struct {
+	mu       sync.Mutex
+	byCaller map[source.CallSite]*inlineCall
+}
+
+var inlineCallSites = &callSites{byCaller: make(map[source.CallSite]*inlineCall)}
+
+// record the actual value passed to Inline from site, and update the expected
+// value in the source when the test ends. Returns an error if the call site
+// was previously used with a different actual value, because the expected
+// literal can only be updated to one of them. The source is not updated after
+// a conflict, and an update made by an earlier subtest is reverted.
+func (c *callSites) record(t assert.TestingT, site source.CallSite, actual, expected string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call, ok := c.byCaller[site]
+	switch {
+	case !ok:
+		call = &inlineCall{actual: actual}
+		c.byCaller[site] = call
+		if actual != expected {
+			return c.updateOnCleanup(t, site, call)
+		}
+		return nil
+	case call.conflict || call.actual == actual:
+		return nil
+	}
+
+	call.conflict = true
+	if call.original != "" {
+		if err := source.RestoreLiteral(site, expectedArgIndex, call.original); err != nil {
+			return err
+		}
+		call.original = ""
+	}
+	return errors.Errorf("the call to Inline at %s was made more than once with different "+
+		"values, the expected value can not be updated from a loop or table-driven test", site)
+}
+
+// updateOnCleanup updates the expected value of the call when the test ends,
+// unless the call site is used with a different value before then. The value
+// is updated immediately if t does not support Cleanup.
+func (c *callSites) updateOnCleanup(
+	t assert.TestingT,
+	site source.CallSite,
+	call *inlineCall,
+) error {
+	ct, ok := t.(cleanupT)
+	if !ok {
+		return c.update(site, call)
+	}
+	ct.Cleanup(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		assert.NilError(t, c.update(site, call), "failed to update the expected value")
+	})
+	return nil
+}
+
+func (c *callSites) update(site source.CallSite, call *inlineCall) error {
+	if call.conflict {
+		return nil
+	}
+	original, err := source.UpdateExpectedValueAt(site, expectedArgIndex, call.actual)
+	call.original = original
+	return err
+}
@@ -0,0 +1,76 @@
+package golden
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/icmd"
+)
+
+func TestInline(t *testing.T) {
+	t.Run("match", func(t *testing.T) {
+		fakeT := new(fakeT)
+		Inline(fakeT, "some\r\nvalue", "some\nvalue")
+		assert.Assert(t, !fakeT.Failed)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		fakeT := new(fakeT)
+		Inline(fakeT, "some value", "other value")
+		assert.Assert(t, fakeT.Failed)
+	})
+}
+
+func TestInline_UpdateGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on a copy of a fixture")
+	}
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go binary not found")
+	}
+	root, err := filepath.Abs("..")
+	assert.NilError(t, err)
+	fixture, err := ioutil.ReadFile("testdata/inline/example_test.go")
+	assert.NilError(t, err)
+	goSum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	assert.NilError(t, err)
+
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("go.mod", "module example\n\n"+
+			"require gotest.tools/v3 v3.0.0\n\n"+
+			"replace gotest.tools/v3 => "+filepath.ToSlash(root)+"\n"),
+		fs.WithFile("go.sum", string(goSum)),
+		fs.WithFile("example_test.go", string(fixture)))
+	defer dir.Remove()
+
+	result := icmd.RunCmd(
+		icmd.Command(goBinary, "test", ".", "-args", "-test.update-golden"),
+		icmd.Dir(dir.Path()),
+		icmd.WithEnv(append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")...))
+	conflict := "the call to Inline at %s:%d was made more than once"
+	result.Assert(t, icmd.Expected{
+		ExitCode: 1,
+		Out:      fmt.Sprintf(conflict, dir.Join("example_test.go"), 17),
+	})
+	assert.Assert(t, cmp.Contains(result.Combined(),
+		fmt.Sprintf(conflict, dir.Join("example_test.go"), 24)))
+	assert.Assert(t, !strings.Contains(result.Combined(), "--- FAIL: TestSingle"))
+
+	// the expected values of TestTable and TestSubtests are not updated
+	expected := strings.Replace(string(fixture), `golden.Inline(t, "new value", "old value")`,
+		`golden.Inline(t, "new value", "new value")`, 1)
+	expected = strings.Replace(expected, "`old\nraw value`", "`new\nraw value`", 1)
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t,
+		fs.WithFile("go.mod", "", fs.MatchAnyFileContent),
+		fs.WithFile("go.sum", "", fs.MatchAnyFileContent),
+		fs.WithFile("example_test.go", expected))))
+}
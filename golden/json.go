@@ -0,0 +1,264 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// AssertJSON compares the JSON encoding of value to the JSON document in the
+// golden file. The documents are compared structurally, so differences in
+// formatting or the order of object keys are ignored.
+//
+// Running `go test pkgname -test.update-golden` will write the canonical JSON
+// encoding of value to the golden file. The canonical encoding uses sorted
+// object keys, and is indented with two spaces.
+//
+// This is equivalent to assert.Assert(t, JSON(value, filename))
+func AssertJSON(t assert.TestingT, value interface{}, filename string, msgAndArgs ...interface{}) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	assert.Assert(t, compareJSON(value, filename, testName(t)), msgAndArgs...)
+}
+
+// JSON compares the JSON encoding of value to the JSON document in filename
+// and returns success if the documents are structurally equal. If the documents
+// are not equal, the failure message lists the path to every value which is
+// different.
+//
+// If value is a []byte or json.RawMessage it must already be a JSON document,
+// otherwise value is encoded with json.Marshal.
+//
+// See AssertJSON for details about updating the golden file.
+func JSON(value interface{}, filename string) cmp.Comparison {
+	return compareJSON(value, filename, "")
+}
+
+func compareJSON(value interface{}, filename string, testName string) cmp.Comparison {
+	return func() cmp.Result {
+		actual, err := canonicalJSON(value)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+
+		if !*flagUpdate || isReviewMode() {
+			goldenUsage.record(Path(filename))
+			if expected, err := ioutil.ReadFile(Path(filename)); err == nil {
+				if diffs, err := diffJSON(expected, actual); err == nil && len(diffs) == 0 {
+					return cmp.ResultSuccess
+				}
+			}
+		}
+
+		result, expected := compare(actual, filename, testName)
+		if result != nil {
+			return result
+		}
+		diffs, err := diffJSON(expected, actual)
+		if err != nil {
+			return cmp.ResultFromError(errors.Wrapf(err, "golden file %s", Path(filename)))
+		}
+		msg := fmt.Sprintf("JSON document does not match golden file %s:\n", Path(filename))
+		return cmp.ResultFailure(msg + strings.Join(diffs, "\n") + failurePostamble(filename))
+	}
+}
+
+func canonicalJSON(value interface{}) ([]byte, error) {
+	var raw []byte
+	switch typed := value.(type) {
+	case []byte:
+		raw = typed
+	case json.RawMessage:
+		raw = typed
+	default:
+		var err error
+		raw, err = json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode value as JSON")
+		}
+	}
+
+	decoded, err := decodeJSON(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode actual value")
+	}
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(decoded)
+	return buf.Bytes(), err
+}
+
+func decodeJSON(raw []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+	return value, nil
+}
+
+func diffJSON(expected, actual []byte) ([]string, error) {
+	x, err := decodeJSON(expected)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode expected value")
+	}
+	y, err := decodeJSON(actual)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode actual value")
+	}
+	return diffJSONValue("$", x, y), nil
+}
+
+// nolint: gocyclo
+func diffJSONValue(path string, x, y interface{}) []string {
+	switch typedX := x.(type) {
+	case map[string]interface{}:
+		typedY, ok := y.(map[string]interface{})
+		if !ok {
+			return []string{typeMismatch(path, x, y)}
+		}
+		var diffs []string
+		for _, key := range sortedUnionKeys(typedX, typedY) {
+			keyPath := path + formatJSONKey(key)
+			valueX, okX := typedX[key]
+			valueY, okY := typedY[key]
+			switch {
+			case !okY:
+				diffs = append(diffs, fmt.Sprintf("%s: missing, expected %s", keyPath, formatJSON(valueX)))
+			case !okX:
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", keyPath, formatJSON(valueY)))
+			default:
+				diffs = append(diffs, diffJSONValue(keyPath, valueX, valueY)...)
+			}
+		}
+		return diffs
+
+	case []interface{}:
+		typedY, ok := y.([]interface{})
+		if !ok {
+			return []string{typeMismatch(path, x, y)}
+		}
+		var diffs []string
+		for i := 0; i < len(typedX) || i < len(typedY); i++ {
+			indexPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(typedY):
+				diffs = append(diffs, fmt.Sprintf("%s: missing, expected %s", indexPath, formatJSON(typedX[i])))
+			case i >= len(typedX):
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", indexPath, formatJSON(typedY[i])))
+			default:
+				diffs = append(diffs, diffJSONValue(indexPath, typedX[i], typedY[i])...)
+			}
+		}
+		return diffs
+
+	case json.Number:
+		typedY, ok := y.(json.Number)
+		switch {
+		case !ok:
+			return []string{typeMismatch(path, x, y)}
+		case !equalNumbers(typedX, typedY):
+			return []string{notEqualJSON(path, x, y)}
+		}
+		return nil
+
+	default:
+		if jsonType(x) != jsonType(y) {
+			return []string{typeMismatch(path, x, y)}
+		}
+		if x != y {
+			return []string{notEqualJSON(path, x, y)}
+		}
+		return nil
+	}
+}
+
+// equalNumbers compares the exact decimal values of x and y, so that numbers
+// which only differ in formatting, like 3 and 3.0, are equal, but integers which
+// are too large to be represented exactly by a float64 are not.
+func equalNumbers(x, y json.Number) bool {
+	if x == y {
+		return true
+	}
+	ratX, okX := new(big.Rat).SetString(string(x))
+	ratY, okY := new(big.Rat).SetString(string(y))
+	return okX && okY && ratX.Cmp(ratY) == 0
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatJSONKey returns the path element for an object key. Keys which are
+// not identifiers use the bracket notation, so that a key like "a.b" is not
+// confused with the nested keys "a" and "b".
+func formatJSONKey(key string) string {
+	if identifierPattern.MatchString(key) {
+		return "." + key
+	}
+	return "[" + formatJSON(key) + "]"
+}
+
+func sortedUnionKeys(x, y map[string]interface{}) []string {
+	keys := make([]string, 0, len(x))
+	for key := range x {
+		keys = append(keys, key)
+	}
+	for key := range y {
+		if _, ok := x[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeMismatch(path string, x, y interface{}) string {
+	return fmt.Sprintf("%s: expected %s %s, got %s %s",
+		path, jsonType(x), formatJSON(x), jsonType(y), formatJSON(y))
+}
+
+func notEqualJSON(path string, x, y interface{}) string {
+	return fmt.Sprintf("%s: expected %s, got %s", path, formatJSON(x), formatJSON(y))
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func formatJSON(value interface{}) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(raw)
+}
@@ -0,0 +1,123 @@
+package golden
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type jsonExample struct {
+	Name  string            `json:"name"`
+	Count int               `json:"count"`
+	Tags  []string          `json:"tags"`
+	Meta  map[string]string `json:"meta,omitempty"`
+}
+
+func TestJSON_SemanticallyEqual(t *testing.T) {
+	filename, clean := setupGoldenFile(t, `{"tags": ["a", "b"], "count": 3.0,
+  "name": "example"}`)
+	defer clean()
+
+	value := jsonExample{Name: "example", Count: 3, Tags: []string{"a", "b"}}
+	assert.Assert(t, JSON(value, filename))
+	assert.Assert(t, JSON([]byte(`{"name":"example","count":3,"tags":["a","b"]}`), filename))
+}
+
+func TestJSON_Failure(t *testing.T) {
+	filename, clean := setupGoldenFile(t, `{
+  "count": 3,
+  "name": "example",
+  "tags": ["a", "b", "c"],
+  "old": true
+}`)
+	defer clean()
+
+	value := jsonExample{
+		Name:  "example",
+		Count: 4,
+		Tags:  []string{"a", "x"},
+		Meta:  map[string]string{"k": "v"},
+	}
+	result := JSON(value, filename)()
+	assert.Assert(t, !result.Success())
+	expected := `JSON document does not match golden file ` + Path(filename) + `:
+$.count: expected 3, got 4
+$.meta: unexpected {"k":"v"}
+$.old: missing, expected true
+$.tags[1]: expected "b", got "x"
+$.tags[2]: missing, expected "c"` + failurePostamble(filename)
+	assert.Equal(t, result.(failure).FailureMessage(), expected)
+}
+
+func TestJSON_TypeMismatch(t *testing.T) {
+	filename, clean := setupGoldenFile(t, `{"count": "3"}`)
+	defer clean()
+
+	result := JSON(json.RawMessage(`{"count": 3}`), filename)()
+	assert.Assert(t, !result.Success())
+	expected := `JSON document does not match golden file ` + Path(filename) + `:
+$.count: expected string "3", got number 3` + failurePostamble(filename)
+	assert.Equal(t, result.(failure).FailureMessage(), expected)
+}
+
+func TestAssertJSON_UpdateGolden(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "")
+	defer clean()
+	unsetUpdateFlag := setUpdateFlag()
+	defer unsetUpdateFlag()
+
+	fakeT := new(fakeT)
+	value := map[string]interface{}{"z": 1, "a": []int{1, 2}, "html": "<b>"}
+	AssertJSON(fakeT, value, filename)
+	assert.Assert(t, !fakeT.Failed)
+
+	unsetUpdateFlag()
+	expected := `{
+  "a": [
+    1,
+    2
+  ],
+  "html": "<b>",
+  "z": 1
+}
+`
+	assert.Equal(t, string(Get(t, filename)), expected)
+}
+
+func TestJSON_LargeIntegers(t *testing.T) {
+	filename, clean := setupGoldenFile(t, `{"id": 9007199254740993, "ratio": 1.50}`)
+	defer clean()
+
+	assert.Assert(t, JSON(json.RawMessage(`{"id": 9007199254740993, "ratio": 15e-1}`), filename))
+
+	result := JSON(json.RawMessage(`{"id": 9007199254740992, "ratio": 1.5}`), filename)()
+	assert.Assert(t, !result.Success())
+	expected := `JSON document does not match golden file ` + Path(filename) + `:
+$.id: expected 9007199254740993, got 9007199254740992` + failurePostamble(filename)
+	assert.Equal(t, result.(failure).FailureMessage(), expected)
+}
+
+func TestJSON_TrailingData(t *testing.T) {
+	filename, clean := setupGoldenFile(t, `{"name": "example"}`)
+	defer clean()
+
+	result := JSON(json.RawMessage(`{"name": "example"} {"name": "other"}`), filename)()
+	assert.Assert(t, !result.Success())
+	assert.Assert(t, cmp.Contains(result.(failure).FailureMessage(),
+		"unexpected data after the JSON document"))
+}
+
+func TestJSON_KeyPaths(t *testing.T) {
+	filename, clean := setupGoldenFile(t, `{"a.b": 1, "a": {"b": 1}, "with space": 1}`)
+	defer clean()
+
+	result := JSON(json.RawMessage(`{"a.b": 2, "a": {"b": 3}, "with space": 4}`), filename)()
+	assert.Assert(t, !result.Success())
+	expected := `JSON document does not match golden file ` + Path(filename) + `:
+$.a.b: expected 1, got 3
+$["a.b"]: expected 1, got 2
+$["with space"]: expected 1, got 4` + failurePostamble(filename)
+	assert.Equal(t, result.(failure).FailureMessage(), expected)
+}
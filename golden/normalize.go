@@ -0,0 +1,166 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/internal/tempdir"
+)
+
+// Normalizer modifies a value before it is compared to the golden file. The
+// normalized value is also the value written to the golden file when running
+// with -test.update-golden.
+//
+// Normalizers are used to replace volatile content, like timestamps or
+// temporary paths, with a stable placeholder.
+type Normalizer func(string) string
+
+// Normalize adds normalizers to the end of the normalization pipeline.
+func Normalize(normalizers ...Normalizer) SettingOp {
+	return func(settings *Settings) {
+		settings.Normalizers = append(settings.Normalizers, normalizers...)
+	}
+}
+
+func (s *Settings) normalize(actual string) string {
+	actual = string(removeCarriageReturn([]byte(actual)))
+	for _, normalizer := range s.Normalizers {
+		actual = normalizer(actual)
+	}
+	return actual
+}
+
+// AssertWith compares actual to the expected value in the golden file, after
+// actual has been modified by the normalizers from ops.
+//
+// Running `go test pkgname -test.update-golden` will write the normalized value
+// of actual to the golden file.
+//
+// Use WithMessage to add a message to the failure.
+//
+// This is equivalent to assert.Assert(t, StringWith(actual, filename, ops...))
+func AssertWith(t assert.TestingT, actual string, filename string, ops ...SettingOp) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	settings := newSettings(ops)
+	settings.testName = testName(t)
+	assert.Assert(t, compareString(actual, filename, settings), settings.msgAndArgs...)
+}
+
+// StringWith compares actual to the contents of filename and returns success
+// if the strings are equal after actual has been modified by the normalizers
+// from ops.
+//
+// See String for more details.
+func StringWith(actual string, filename string, ops ...SettingOp) cmp.Comparison {
+	return compareString(actual, filename, newSettings(ops))
+}
+
+// ReplaceRegexp returns a Normalizer which replaces all matches of re with
+// replacement. The replacement may reference submatches using the syntax
+// supported by regexp.Regexp.ReplaceAllString.
+func ReplaceRegexp(re *regexp.Regexp, replacement string) Normalizer {
+	return func(value string) string {
+		return re.ReplaceAllString(value, replacement)
+	}
+}
+
+var (
+	rfc3339Pattern = regexp.MustCompile(
+		`\d{4}-\d{2}-\d{2}[Tt]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})`)
+	uuidPattern = regexp.MustCompile(
+		`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	hexAddressPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]{6,}\b`)
+	durationPattern   = regexp.MustCompile(
+		`\b((\d+h)?(\d+m)?\d+(\.\d+)?s|\d+(\.\d+)?(ns|us|µs|ms))\b`)
+)
+
+// ScrubRFC3339Times replaces all RFC3339 timestamps with <TIME>.
+func ScrubRFC3339Times(value string) string {
+	return rfc3339Pattern.ReplaceAllString(value, "<TIME>")
+}
+
+// ScrubUUIDs replaces all UUIDs with <UUID>.
+func ScrubUUIDs(value string) string {
+	return uuidPattern.ReplaceAllString(value, "<UUID>")
+}
+
+// ScrubHexAddresses replaces all hexadecimal memory addresses, like the ones
+// printed for pointers, with <ADDR>. Only hex numbers with at least 6 digits are
+// replaced.
+func ScrubHexAddresses(value string) string {
+	return hexAddressPattern.ReplaceAllString(value, "<ADDR>")
+}
+
+// ScrubDurations replaces all durations formatted by time.Duration.String
+// with <DURATION>. Only values which end in a unit of seconds, like 1h0m0s,
+// 2.5s, or 300ms, are replaced. A number followed only by h or m is not a
+// duration formatted by time.Duration.String, and is preserved.
+func ScrubDurations(value string) string {
+	return durationPattern.ReplaceAllString(value, "<DURATION>")
+}
+
+// ScrubPath returns a Normalizer which replaces all occurrences of path with
+// placeholder. Path is replaced in both its native and slash separated form.
+//
+// ScrubPath is useful with the path of a temporary fs.Dir:
+//
+//   golden.AssertWith(t, out, "out.golden",
+//       golden.Normalize(golden.ScrubPath(dir.Path(), "<DIR>")))
+func ScrubPath(path string, placeholder string) Normalizer {
+	return func(value string) string {
+		value = strings.Replace(value, path, placeholder, -1)
+		return strings.Replace(value, filepath.ToSlash(path), placeholder, -1)
+	}
+}
+
+// ScrubTempDirs replaces the paths of temporary directories and files created
+// by fs.NewDir and fs.NewFile with <TMPDIR>. These paths are a generated name,
+// made from the prefix, a dash, and random digits, in the temporary directory.
+// Paths created by ioutil.TempDir or ioutil.TempFile are only replaced when the
+// pattern ends in a dash.
+//
+// The temporary directory is the directory set by GOTESTTOOLS_FS_TEMPDIR, or
+// os.TempDir, including the path with symlinks resolved (for example the
+// /private prefix on macOS). It is read each time ScrubTempDirs is called.
+//
+// Only the temporary directory itself is replaced, any path elements which
+// follow it are preserved, so a directory created with fs.WithParent in a
+// temporary directory is replaced as part of the path of its parent.
+func ScrubTempDirs(value string) string {
+	return tempDirPattern().ReplaceAllString(value, "<TMPDIR>")
+}
+
+func tempDirPattern() *regexp.Regexp {
+	var roots []string
+	add := func(root string) {
+		if root == "" {
+			return
+		}
+		roots = append(roots, regexp.QuoteMeta(filepath.Clean(root)))
+		if resolved, err := filepath.EvalSymlinks(root); err == nil && resolved != root {
+			roots = append(roots, regexp.QuoteMeta(resolved))
+		}
+	}
+	add(os.Getenv(tempdir.EnvTempDir))
+	add(os.TempDir())
+	// Longer roots first, so that a resolved path is not partially matched by
+	// a root which is a prefix of it.
+	sort.Slice(roots, func(i, j int) bool {
+		return len(roots[i]) > len(roots[j])
+	})
+	return regexp.MustCompile(
+		`(` + strings.Join(roots, "|") + `)[/\\][^/\\\s]+-\d+`)
+}
+
+// ToSlash replaces each path separator with a slash, so that paths printed on
+// Windows match the paths printed on other platforms.
+func ToSlash(value string) string {
+	return filepath.ToSlash(value)
+}
@@ -0,0 +1,136 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/env"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/internal/tempdir"
+)
+
+func TestScrubbers(t *testing.T) {
+	var testcases = []struct {
+		name       string
+		normalizer Normalizer
+		value      string
+		expected   string
+	}{
+		{
+			name:       "RFC3339 times",
+			normalizer: ScrubRFC3339Times,
+			value:      "start=2020-05-01T15:04:05Z end=2020-05-01T15:04:05.999+02:00",
+			expected:   "start=<TIME> end=<TIME>",
+		},
+		{
+			name:       "UUIDs",
+			normalizer: ScrubUUIDs,
+			value:      "id: 123e4567-e89b-12d3-a456-426614174000.",
+			expected:   "id: <UUID>.",
+		},
+		{
+			name:       "hex addresses",
+			normalizer: ScrubHexAddresses,
+			value:      "ptr=0xc000012345 flag=0x1",
+			expected:   "ptr=<ADDR> flag=0x1",
+		},
+		{
+			name:       "durations",
+			normalizer: ScrubDurations,
+			value:      "took 1m2.5s, then 300ms, then 45µs, then 1h0m0s on line 3",
+			expected:   "took <DURATION>, then <DURATION>, then <DURATION>, then <DURATION> on line 3",
+		},
+		{
+			name:       "not durations",
+			normalizer: ScrubDurations,
+			value:      "2h ago, 10m wide, 3 s, 1h2m",
+			expected:   "2h ago, 10m wide, 3 s, 1h2m",
+		},
+		{
+			name:       "path",
+			normalizer: ScrubPath(filepath.Join("some", "dir"), "<DIR>"),
+			value:      "file " + filepath.Join("some", "dir", "file") + " and some/dir/other",
+			expected:   "file " + filepath.Join("<DIR>", "file") + " and <DIR>/other",
+		},
+		{
+			name:       "temp dirs",
+			normalizer: ScrubTempDirs,
+			value:      "in " + filepath.Join(os.TempDir(), "TestFoo-12345", "file"),
+			expected:   "in " + filepath.Join("<TMPDIR>", "file"),
+		},
+		{
+			name:       "regexp",
+			normalizer: ReplaceRegexp(regexp.MustCompile(`port (\d+)`), "port <PORT>"),
+			value:      "listening on port 34567",
+			expected:   "listening on port <PORT>",
+		},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			assert.Equal(t, testcase.normalizer(testcase.value), testcase.expected)
+		})
+	}
+}
+
+func TestScrubTempDirs_EnvTempDir(t *testing.T) {
+	root := fs.NewDir(t, "root")
+	defer root.Remove()
+	defer env.Patch(t, tempdir.EnvTempDir, root.Path())()
+
+	value := "in " + filepath.Join(root.Path(), "TestFoo-12345", "file")
+	assert.Equal(t, ScrubTempDirs(value), "in "+filepath.Join("<TMPDIR>", "file"))
+}
+
+func TestScrubTempDirs_ResolvedTempDir(t *testing.T) {
+	root := fs.NewDir(t, "root", fs.WithDir("real"), fs.WithSymlink("link", "real"))
+	defer root.Remove()
+	defer env.Patch(t, "TMPDIR", root.Join("link"))()
+	defer env.Patch(t, "TMP", root.Join("link"))()
+
+	value := "in " + filepath.Join(root.Join("real"), "TestFoo-12345", "file")
+	assert.Equal(t, ScrubTempDirs(value), "in "+filepath.Join("<TMPDIR>", "file"))
+}
+
+func TestAssertWith_WithMessage(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "expected\n")
+	defer clean()
+
+	fakeT := new(fakeT)
+	AssertWith(fakeT, "actual\n", filename, WithMessage("extra %s", "context"))
+	assert.Assert(t, fakeT.Failed)
+	assert.Assert(t, cmp.Contains(strings.Join(fakeT.Logs, "\n"), "extra context"))
+}
+
+func TestAssertWith(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "started at <TIME>\nid <UUID>\n")
+	defer clean()
+
+	fakeT := new(fakeT)
+	AssertWith(fakeT,
+		"started at 2020-05-01T15:04:05Z\r\nid 123e4567-e89b-12d3-a456-426614174000\r\n",
+		filename,
+		Normalize(ScrubRFC3339Times, ScrubUUIDs))
+	assert.Assert(t, !fakeT.Failed)
+
+	AssertWith(fakeT, "started at 2020-05-01T15:04:05Z\n", filename)
+	assert.Assert(t, fakeT.Failed)
+}
+
+func TestAssertWith_UpdateGolden(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "")
+	defer clean()
+	unsetUpdateFlag := setUpdateFlag()
+	defer unsetUpdateFlag()
+
+	fakeT := new(fakeT)
+	AssertWith(fakeT, "took 1.5s\n", filename, Normalize(ScrubDurations))
+	assert.Assert(t, !fakeT.Failed)
+
+	unsetUpdateFlag()
+	assert.Equal(t, string(Get(t, filename)), "took <DURATION>\n")
+}
@@ -0,0 +1,258 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/internal/format"
+)
+
+// AssertTemplate compares actual to the golden file after the golden file is
+// rendered as a text/template with data. AssertTemplate is useful when the
+// expected value contains values which are only known when the test runs, like
+// a port number or a temporary path.
+//
+// Running `go test pkgname -test.update-golden` will write actual to the golden
+// file, after replacing every occurrence of a value from data with the template
+// action which renders that value. Data must be a struct, or a map with string
+// keys, for values to be replaced. Values are replaced using their fmt.Sprint
+// representation, and empty values are never replaced. A value is not replaced
+// where it is part of a longer word or number, and values shorter than four
+// characters are only replaced when they occur once. Any other occurrence must
+// be replaced by editing the golden file. A golden file which already renders
+// actual is not rewritten, so those edits are kept.
+//
+// This is equivalent to assert.Assert(t, Template(actual, filename, data))
+func AssertTemplate(
+	t assert.TestingT,
+	actual string,
+	filename string,
+	data interface{},
+	msgAndArgs ...interface{},
+) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	assert.Assert(t, compareTemplate(actual, filename, data, testName(t)), msgAndArgs...)
+}
+
+// Template compares actual to the contents of filename, rendered as a
+// text/template with data, and returns success if the strings are equal.
+//
+// See AssertTemplate for details about updating the golden file.
+func Template(actual string, filename string, data interface{}) cmp.Comparison {
+	return compareTemplate(actual, filename, data, "")
+}
+
+func compareTemplate(
+	actual string,
+	filename string,
+	data interface{},
+	testName string,
+) cmp.Comparison {
+	return func() cmp.Result {
+		actual = string(removeCarriageReturn([]byte(actual)))
+		// the golden file is only rewritten when it does not already render
+		// actual, so that placeholders added by hand are kept.
+		if *flagUpdate && !templateRenders(filename, data, actual) {
+			content := templateFromValue(actual, data)
+			result, _ := compare([]byte(content), filename, testName)
+			if result != nil && !result.Success() {
+				return result
+			}
+		}
+
+		goldenUsage.record(Path(filename))
+		raw, err := ioutil.ReadFile(Path(filename))
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		expected, err := renderTemplate(filename, string(raw), data)
+		if err != nil {
+			return cmp.ResultFromError(err)
+		}
+		if expected == actual {
+			return cmp.ResultSuccess
+		}
+		diff := format.UnifiedDiff(format.DiffConfig{
+			A:    expected,
+			B:    actual,
+			From: "expected",
+			To:   "actual",
+		})
+		return cmp.ResultFailure("\n" + diff + failurePostamble(filename))
+	}
+}
+
+// templateRenders returns true if the golden file exists, and renders actual
+// when executed with data.
+func templateRenders(filename string, data interface{}, actual string) bool {
+	raw, err := ioutil.ReadFile(Path(filename))
+	if err != nil {
+		return false
+	}
+	rendered, err := renderTemplate(filename, string(raw), data)
+	return err == nil && rendered == actual
+}
+
+func renderTemplate(name string, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse golden file %s: %s", Path(name), err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to render golden file %s: %s", Path(name), err)
+	}
+	return buf.String(), nil
+}
+
+// templateFromValue returns a template which renders value when executed with
+// data. Every occurrence of a value from data is replaced with an action that
+// renders the value. Longer values are replaced before shorter values.
+//
+// A value is only replaced where it is not part of a longer word or number, so
+// that a Port of 1 does not replace the 1 in 10. Values shorter than
+// minAmbiguousLength are likely to appear in the text by chance, so they are
+// only replaced when they occur exactly once.
+func templateFromValue(value string, data interface{}) string {
+	placeholders := templatePlaceholders(data)
+	sort.SliceStable(placeholders, func(i, j int) bool {
+		return len(placeholders[i].value) > len(placeholders[j].value)
+	})
+	var candidates []placeholder
+	for _, p := range placeholders {
+		if len(p.value) >= minAmbiguousLength || countWords(value, p.value) == 1 {
+			candidates = append(candidates, p)
+		}
+	}
+
+	buf := new(strings.Builder)
+	for i := 0; i < len(value); {
+		// escape template delimiters which are already in the value
+		if strings.HasPrefix(value[i:], "{{") {
+			buf.WriteString(`{{"{{"}}`)
+			i += 2
+			continue
+		}
+		p, ok := placeholderAt(value, i, candidates)
+		if ok {
+			buf.WriteString(p.action)
+			i += len(p.value)
+			continue
+		}
+		buf.WriteByte(value[i])
+		i++
+	}
+	return buf.String()
+}
+
+// minAmbiguousLength is the length of the shortest value which is replaced
+// everywhere it occurs by templateFromValue.
+const minAmbiguousLength = 4
+
+func placeholderAt(value string, pos int, placeholders []placeholder) (placeholder, bool) {
+	for _, p := range placeholders {
+		if strings.HasPrefix(value[pos:], p.value) && isWordAt(value, pos, len(p.value)) {
+			return p, true
+		}
+	}
+	return placeholder{}, false
+}
+
+// countWords returns the number of times word appears in value, where it is not
+// part of a longer word.
+func countWords(value, word string) int {
+	count := 0
+	for i := 0; i+len(word) <= len(value); i++ {
+		if strings.HasPrefix(value[i:], word) && isWordAt(value, i, len(word)) {
+			count++
+			i += len(word) - 1
+		}
+	}
+	return count
+}
+
+// isWordAt returns true if the length bytes at pos in value are not joined to
+// the word characters before or after them.
+func isWordAt(value string, pos int, length int) bool {
+	first, _ := utf8.DecodeRuneInString(value[pos:])
+	last, _ := utf8.DecodeLastRuneInString(value[:pos+length])
+	before, _ := utf8.DecodeLastRuneInString(value[:pos])
+	after, _ := utf8.DecodeRuneInString(value[pos+length:])
+	return !(pos > 0 && isWordRune(before) && isWordRune(first)) &&
+		!(pos+length < len(value) && isWordRune(after) && isWordRune(last))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type placeholder struct {
+	value  string
+	action string
+}
+
+func templatePlaceholders(data interface{}) []placeholder {
+	var placeholders []placeholder
+	add := func(action string, value reflect.Value) {
+		if !value.IsValid() || !value.CanInterface() {
+			return
+		}
+		str := fmt.Sprint(value.Interface())
+		if str == "" {
+			return
+		}
+		placeholders = append(placeholders, placeholder{value: str, action: action})
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(data))
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, key := range keys {
+			add(mapKeyAction(key.String()), v.MapIndex(key))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.PkgPath == "" {
+				add("{{."+field.Name+"}}", v.Field(i))
+			}
+		}
+	}
+	return placeholders
+}
+
+func mapKeyAction(key string) string {
+	if isIdentifier(key) {
+		return "{{." + key + "}}"
+	}
+	return fmt.Sprintf("{{index . %q}}", key)
+}
+
+func isIdentifier(key string) bool {
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return key != ""
+}
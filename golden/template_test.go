@@ -0,0 +1,100 @@
+package golden
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type templateData struct {
+	Port    int
+	Dir     string
+	Empty   string
+	private string
+}
+
+func TestTemplate(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "listening on :{{.Port}}\nroot {{.Dir}}")
+	defer clean()
+
+	data := templateData{Port: 34567, Dir: "/tmp/root-123"}
+	assert.Assert(t, Template("listening on :34567\nroot /tmp/root-123", filename, data))
+
+	result := Template("listening on :80\nroot /tmp/root-123", filename, data)()
+	assert.Assert(t, !result.Success())
+	expected := `
+--- expected
++++ actual
+@@ -1,2 +1,2 @@
+-listening on :34567
++listening on :80
+ root /tmp/root-123
+` + failurePostamble(filename)
+	assert.Equal(t, result.(failure).FailureMessage(), expected)
+}
+
+func TestTemplate_MissingKey(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "{{.missing}}")
+	defer clean()
+
+	result := Template("", filename, map[string]string{})()
+	assert.Assert(t, !result.Success())
+}
+
+func TestAssertTemplate_UpdateGolden(t *testing.T) {
+	filename, clean := setupGoldenFile(t, "")
+	defer clean()
+	unsetUpdateFlag := setUpdateFlag()
+	defer unsetUpdateFlag()
+
+	data := map[string]interface{}{
+		"port":     8080,
+		"temp-dir": "/tmp/dir",
+		"prefix":   "/tmp",
+		"empty":    "",
+	}
+	fakeT := new(fakeT)
+	AssertTemplate(fakeT, "dir=/tmp/dir/x port=8080 other=/tmp {{literal}}\n", filename, data)
+	assert.Assert(t, !fakeT.Failed)
+
+	unsetUpdateFlag()
+	expected := `dir={{index . "temp-dir"}}/x port={{.port}} other={{.prefix}} ` +
+		`{{"{{"}}literal}}` + "\n"
+	assert.Equal(t, string(Get(t, filename)), expected)
+
+	AssertTemplate(t, "dir=/tmp/dir/x port=8080 other=/tmp {{literal}}\n", filename, data)
+}
+
+func TestTemplateFromValue_Struct(t *testing.T) {
+	data := templateData{Port: 80, Dir: "/root", private: "secret"}
+	actual := templateFromValue("port 80 in /root secret", data)
+	assert.Equal(t, actual, "port {{.Port}} in {{.Dir}} secret")
+}
+
+func TestTemplateFromValue_WordBoundaries(t *testing.T) {
+	data := map[string]interface{}{"port": 80, "host": "local", "id": 1}
+	actual := templateFromValue("local:80 localhost:8080 id=1 10.12", data)
+	assert.Equal(t, actual, "{{.host}}:{{.port}} localhost:8080 id={{.id}} 10.12")
+}
+
+func TestTemplateFromValue_AmbiguousShortValue(t *testing.T) {
+	data := map[string]interface{}{"port": 1, "name": "server"}
+	actual := templateFromValue("server on port 1, retried 1 time", data)
+	assert.Equal(t, actual, "{{.name}} on port 1, retried 1 time")
+}
+
+func TestAssertTemplate_UpdateGoldenKeepsPlaceholders(t *testing.T) {
+	golden := "retry {{.N}} of 3, port 3\n"
+	filename, clean := setupGoldenFile(t, golden)
+	defer clean()
+	unsetUpdateFlag := setUpdateFlag()
+	defer unsetUpdateFlag()
+
+	data := map[string]int{"N": 3}
+	fakeT := new(fakeT)
+	AssertTemplate(fakeT, "retry 3 of 3, port 3\n", filename, data)
+	assert.Assert(t, !fakeT.Failed)
+
+	unsetUpdateFlag()
+	assert.Equal(t, string(Get(t, filename)), golden)
+}
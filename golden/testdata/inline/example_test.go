@@ -0,0 +1,27 @@
+package example
+
+import (
+	"testing"
+
+	"gotest.tools/v3/golden"
+)
+
+func TestSingle(t *testing.T) {
+	golden.Inline(t, "new value", "old value")
+	golden.Inline(t, "new\nraw value", `old
+raw value`)
+}
+
+func TestTable(t *testing.T) {
+	for _, value := range []string{"one", "two"} {
+		golden.Inline(t, value, "old value")
+	}
+}
+
+func TestSubtests(t *testing.T) {
+	for _, value := range []string{"one", "two"} {
+		t.Run(value, func(t *testing.T) {
+			golden.Inline(t, value, "old value")
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package golden
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestingT is the subset of testing.T used by AssertT. It extends
+// assert.TestingT with Name, which is used to derive the golden filename.
+type TestingT interface {
+	assert.TestingT
+	Name() string
+}
+
+const defaultExtension = ".golden"
+
+// WithSuffix sets a suffix which is appended to the filename derived from the
+// test name by AssertT. A suffix is required when a test calls AssertT more than
+// once.
+func WithSuffix(suffix string) SettingOp {
+	return func(settings *Settings) {
+		settings.Suffix = suffix
+	}
+}
+
+// WithExtension sets the extension of the golden file used by AssertT. The
+// default extension is .golden. Like the test name, any characters in ext which
+// are not safe for filenames are replaced.
+func WithExtension(ext string) SettingOp {
+	return func(settings *Settings) {
+		settings.Extension = ext
+	}
+}
+
+// AssertT compares actual to the expected value in a golden file named after
+// the test. The filename is derived from t.Name(), with subtest separators
+// replaced by a dash, and any characters which are not safe for filenames
+// replaced by an underscore. For example a subtest named "TestFoo/with spaces"
+// uses the golden file testdata/TestFoo-with_spaces.golden.
+//
+// AssertT fails the test if two calls from the same test, or from tests with
+// names that are the same after they are sanitized, would use the same golden
+// file. Use WithSuffix to use a different file for each call. Two calls from
+// the same test are only detected when t supports Cleanup, which requires
+// Go 1.14 or later.
+//
+// Running `go test pkgname -test.update-golden` will write the value of actual
+// to the golden file.
+func AssertT(t TestingT, actual string, ops ...SettingOp) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	settings := newSettings(ops)
+	settings.testName = t.Name()
+	filename := filenameFromTestName(t.Name(), settings)
+	if err := goldenOwners.claim(Path(filename), t); err != nil {
+		t.Log(err.Error())
+		t.FailNow()
+		return
+	}
+	assert.Assert(t, compareString(actual, filename, settings), settings.msgAndArgs...)
+}
+
+func filenameFromTestName(name string, settings *Settings) string {
+	ext := settings.Extension
+	if ext == "" {
+		ext = defaultExtension
+	}
+	return sanitizeFilename(name) + sanitizeFilename(settings.Suffix) + sanitizeFilename(ext)
+}
+
+func sanitizeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '/':
+			return '-'
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+type cleanupT interface {
+	Cleanup(f func())
+}
+
+type claim struct {
+	name string
+	// released is true when the claim is released by a cleanup function at the
+	// end of the test.
+	released bool
+}
+
+type owners struct {
+	mu     sync.Mutex
+	byPath map[string]claim
+}
+
+var goldenOwners = &owners{byPath: make(map[string]claim)}
+
+// claim records that t uses the golden file at path. An error is returned if
+// the path was already claimed by a test with a different name, or by the same
+// test.
+//
+// The claim is released when the test ends, so that tests can run more than
+// once with -count. If t does not support Cleanup the claim is never released,
+// and another claim by a test with the same name is allowed, because it can not
+// be distinguished from a test which runs again.
+func (o *owners) claim(path string, t TestingT) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ct, canRelease := t.(cleanupT)
+	previous, ok := o.byPath[path]
+	switch {
+	case !ok:
+	case previous.name != t.Name():
+		return fmt.Errorf("golden file %s is used by both %s and %s, "+
+			"use golden.WithSuffix to choose a unique filename", path, previous.name, t.Name())
+	case previous.released:
+		return fmt.Errorf("golden file %s is used more than once by %s, "+
+			"use golden.WithSuffix to choose a unique filename", path, t.Name())
+	}
+	o.byPath[path] = claim{name: t.Name(), released: canRelease}
+	if canRelease {
+		ct.Cleanup(func() {
+			o.release(path)
+		})
+	}
+	return nil
+}
+
+func (o *owners) release(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.byPath, path)
+}
@@ -0,0 +1,120 @@
+package golden
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type namedFakeT struct {
+	fakeT
+	name string
+}
+
+func (t *namedFakeT) Name() string {
+	return t.name
+}
+
+type cleanupFakeT struct {
+	namedFakeT
+	cleanups []func()
+}
+
+func (t *cleanupFakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *cleanupFakeT) runCleanups() {
+	for _, f := range t.cleanups {
+		f()
+	}
+	t.cleanups = nil
+}
+
+func TestFilenameFromTestName(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		settings Settings
+		expected string
+	}{
+		{name: "TestFoo", expected: "TestFoo.golden"},
+		{name: "TestFoo/sub_test#01", expected: "TestFoo-sub_test_01.golden"},
+		{name: "TestFoo/a:b*c", expected: "TestFoo-a_b_c.golden"},
+		{
+			name:     "TestFoo/case",
+			settings: Settings{Suffix: "-stdout", Extension: ".json"},
+			expected: "TestFoo-case-stdout.json",
+		},
+		{
+			name:     "TestFoo",
+			settings: Settings{Extension: "/../../escape"},
+			expected: "TestFoo-..-..-escape",
+		},
+	}
+	for _, testcase := range testcases {
+		settings := testcase.settings
+		actual := filenameFromTestName(testcase.name, &settings)
+		assert.Equal(t, actual, testcase.expected)
+	}
+}
+
+func TestAssertT(t *testing.T) {
+	undo := setUpdateFlag()
+	defer undo()
+
+	fakeT := &cleanupFakeT{namedFakeT: namedFakeT{name: t.Name() + "/first"}}
+	filename := t.Name() + "-first.golden"
+	defer os.Remove(Path(filename))
+	AssertT(fakeT, "content")
+	assert.Assert(t, !fakeT.Failed)
+	assert.Equal(t, string(Get(t, filename)), "content")
+
+	t.Run("same test without suffix", func(t *testing.T) {
+		AssertT(fakeT, "content")
+		assert.Assert(t, fakeT.Failed)
+	})
+
+	t.Run("same test with suffix", func(t *testing.T) {
+		fakeT.Failed = false
+		defer os.Remove(Path("TestAssertT-first-other.golden"))
+		AssertT(fakeT, "content", WithSuffix("-other"))
+		assert.Assert(t, !fakeT.Failed)
+	})
+
+	t.Run("different test with same sanitized name", func(t *testing.T) {
+		other := &namedFakeT{name: "TestAssertT-first"}
+		AssertT(other, "content")
+		assert.Assert(t, other.Failed)
+	})
+
+	t.Run("same test name run again", func(t *testing.T) {
+		fakeT.runCleanups()
+		again := &cleanupFakeT{namedFakeT: namedFakeT{name: fakeT.name}}
+		AssertT(again, "content")
+		assert.Assert(t, !again.Failed)
+		again.runCleanups()
+	})
+}
+
+func TestAssertT_WithoutCleanup(t *testing.T) {
+	undo := setUpdateFlag()
+	defer undo()
+
+	fakeT := &namedFakeT{name: t.Name()}
+	defer os.Remove(Path(t.Name() + ".golden"))
+	AssertT(fakeT, "content")
+	assert.Assert(t, !fakeT.Failed)
+
+	t.Run("same test name run again", func(t *testing.T) {
+		again := &namedFakeT{name: fakeT.name}
+		AssertT(again, "content")
+		assert.Assert(t, !again.Failed)
+	})
+
+	t.Run("different test with same sanitized name", func(t *testing.T) {
+		other := &namedFakeT{name: "TestAssertT:WithoutCleanup"}
+		AssertT(other, "content")
+		assert.Assert(t, other.Failed)
+	})
+}
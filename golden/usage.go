@@ -0,0 +1,127 @@
+package golden
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var flagPrune = flag.Bool("test.update-golden-prune", false,
+	"remove unused golden files when used with -test.update-golden and TrackUsage")
+
+type usage struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+var goldenUsage = &usage{paths: make(map[string]bool)}
+
+func (u *usage) record(path string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.paths[baseVariant(filepath.Clean(path))] = true
+}
+
+func (u *usage) used(path string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.paths[baseVariant(filepath.Clean(path))]
+}
+
+// TrackUsage runs the tests and reports any golden files in ./testdata which
+// were not read or written by Open, Get, Assert, String, or any of the other
+// functions in this package, or by fs.ContentGolden. TrackUsage is intended
+// to be called from TestMain:
+//
+//   func TestMain(m *testing.M) {
+//       os.Exit(golden.TrackUsage(m))
+//   }
+//
+// Any file in ./testdata with .golden in its name is considered a golden file.
+// All the variants of a golden file (see Path) are considered used when any one
+// of them is used.
+// If any golden files were unused, the names of the files are printed and the
+// returned exit code is non-zero. Unused files are only reported when all the
+// tests passed, and no tests were excluded with -test.run.
+//
+// When run with -test.update-golden and -test.update-golden-prune the unused
+// golden files are removed instead of being reported.
+func TrackUsage(m *testing.M) int {
+	return trackUsage(m.Run, "testdata", os.Stderr)
+}
+
+func trackUsage(run func() int, root string, out io.Writer) int {
+	code := run()
+	if code != 0 || isFilteredRun() {
+		return code
+	}
+
+	unused, err := unusedGoldenFiles(root)
+	if err != nil {
+		fmt.Fprintf(out, "failed to find unused golden files: %s\n", err)
+		return 1
+	}
+	if len(unused) == 0 {
+		return code
+	}
+
+	if *flagUpdate && *flagPrune {
+		for _, path := range unused {
+			if err := os.Remove(path); err != nil {
+				fmt.Fprintf(out, "failed to remove unused golden file: %s\n", err)
+				return 1
+			}
+			fmt.Fprintf(out, "removed unused golden file %s\n", path)
+		}
+		return code
+	}
+
+	fmt.Fprintln(out, "golden files were not used by any test:")
+	for _, path := range unused {
+		fmt.Fprintln(out, "    "+path)
+	}
+	fmt.Fprintln(out, "\nYou can run 'go test . -test.update-golden -test.update-golden-prune' "+
+		"to remove the unused files.")
+	return 1
+}
+
+func isFilteredRun() bool {
+	run := flag.Lookup("test.run")
+	return run != nil && run.Value.String() != ""
+}
+
+func unusedGoldenFiles(root string) ([]string, error) {
+	var unused []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err) && path == root:
+			return filepath.SkipDir
+		case err != nil:
+			return err
+		case info.IsDir() && strings.HasSuffix(info.Name(), PendingSuffix):
+			// a pending directory written by AssertDir is not a golden file
+			return filepath.SkipDir
+		case info.IsDir():
+			return nil
+		}
+		if isGoldenFilename(info.Name()) && !goldenUsage.used(path) {
+			unused = append(unused, path)
+		}
+		return nil
+	})
+	sort.Strings(unused)
+	return unused, err
+}
+
+func isGoldenFilename(name string) bool {
+	if strings.HasSuffix(name, PendingSuffix) {
+		return false
+	}
+	return strings.HasSuffix(name, ".golden") || strings.Contains(name, ".golden.")
+}
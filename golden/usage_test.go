@@ -0,0 +1,98 @@
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+)
+
+func TestTrackUsage(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithDir("testdata",
+			fs.WithFile("used.golden", "used"),
+			fs.WithFile("unused.golden", ""),
+			fs.WithFile("pending.golden.new", ""),
+			fs.WithDir("tree.new", fs.WithFile("out.golden", "")),
+			fs.WithFile("fixture.txt", ""),
+			fs.WithDir("sub",
+				fs.WithFile("unused.golden.json", "{}"))))
+	defer dir.Remove()
+	root := dir.Join("testdata")
+
+	// Unset -test.run so that this test also works when it is selected with -run
+	if run := flag.Lookup("test.run"); run != nil {
+		orig := run.Value.String()
+		assert.NilError(t, run.Value.Set(""))
+		defer run.Value.Set(orig) // nolint: errcheck
+	}
+
+	t.Run("failed run is not checked", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		code := trackUsage(func() int { return 3 }, root, out)
+		assert.Equal(t, code, 3)
+		assert.Equal(t, out.String(), "")
+	})
+
+	t.Run("unused files are reported", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		run := func() int {
+			Assert(t, "used", dir.Join("testdata/used.golden"))
+			return 0
+		}
+		code := trackUsage(run, root, out)
+		assert.Equal(t, code, 1)
+		expected := "golden files were not used by any test:\n" +
+			"    " + dir.Join("testdata/sub/unused.golden.json") + "\n" +
+			"    " + dir.Join("testdata/unused.golden") + "\n" +
+			"\nYou can run 'go test . -test.update-golden -test.update-golden-prune' " +
+			"to remove the unused files.\n"
+		assert.Equal(t, out.String(), expected)
+	})
+
+	t.Run("unused files are removed", func(t *testing.T) {
+		undo := setUpdateFlag()
+		defer undo()
+		*flagPrune = true
+		defer func() { *flagPrune = false }()
+
+		out := new(bytes.Buffer)
+		code := trackUsage(func() int { return 0 }, root, out)
+		assert.Equal(t, code, 0)
+
+		expected := fs.Expected(t,
+			fs.WithMode(0755),
+			fs.WithFile("used.golden", "used"),
+			fs.WithFile("pending.golden.new", ""),
+			fs.WithDir("tree.new", fs.WithFile("out.golden", "")),
+			fs.WithFile("fixture.txt", ""),
+			fs.WithDir("sub"))
+		assert.Assert(t, fs.Equal(root, expected))
+	})
+}
+
+func TestTrackUsage_ContentGolden(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithDir("testdata", fs.WithFile("content.golden", "content")),
+		fs.WithFile("actual", "content"))
+	defer dir.Remove()
+	root := dir.Join("testdata")
+
+	if run := flag.Lookup("test.run"); run != nil {
+		orig := run.Value.String()
+		assert.NilError(t, run.Value.Set(""))
+		defer run.Value.Set(orig) // nolint: errcheck
+	}
+
+	out := new(bytes.Buffer)
+	run := func() int {
+		assert.Assert(t, fs.EqualFile(dir.Join("actual"),
+			fs.ContentGolden(dir.Join("testdata/content.golden"))))
+		return 0
+	}
+	code := trackUsage(run, root, out)
+	assert.Equal(t, code, 0)
+	assert.Equal(t, out.String(), "")
+}
@@ -0,0 +1,121 @@
+package golden
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gotest.tools/v3/internal/source"
+)
+
+// resolveVariant returns the path to the most specific variant of the golden
+// file at path which exists. See Path for the lookup order. If no variant
+// exists, path is returned.
+func resolveVariant(path string) string {
+	dir, base := filepath.Split(path)
+	name, ext := splitExt(base)
+	entries, err := ioutil.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return path
+	}
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+
+	osName := name + "." + runtime.GOOS
+	candidates := []string{
+		versionVariant(names, osName, ext),
+		osName + ext,
+		versionVariant(names, name, ext),
+	}
+	for _, candidate := range candidates {
+		if candidate != "" && names[candidate] {
+			return filepath.Join(dir, candidate)
+		}
+	}
+	return path
+}
+
+func splitExt(filename string) (string, string) {
+	ext := filepath.Ext(filename)
+	if ext == filename {
+		return filename, ""
+	}
+	return strings.TrimSuffix(filename, ext), ext
+}
+
+var goVersionPattern = regexp.MustCompile(`^go(\d+)\.(\d+)$`)
+
+// versionVariant returns the filename of the variant of name with the highest
+// Go version which is not greater than the current runtime version.
+func versionVariant(names map[string]bool, name string, ext string) string {
+	var selected string
+	var selectedMajor, selectedMinor int64 = -1, -1
+	for filename := range names {
+		if !strings.HasPrefix(filename, name+".") || !strings.HasSuffix(filename, ext) {
+			continue
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(filename, name+"."), ext)
+		major, minor, ok := parseGoVersion(version)
+		if !ok || source.GoVersionLessThan(major, minor) {
+			continue
+		}
+		if major > selectedMajor || (major == selectedMajor && minor > selectedMinor) {
+			selected, selectedMajor, selectedMinor = filename, major, minor
+		}
+	}
+	return selected
+}
+
+func parseGoVersion(version string) (int64, int64, bool) {
+	match := goVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return 0, 0, false
+	}
+	major, err := strconv.ParseInt(match[1], 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.ParseInt(match[2], 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// knownOS is the list of values for runtime.GOOS. It is used to recognize
+// variants of golden files created on other platforms.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "hurd": true, "illumos": true, "ios": true, "js": true,
+	"linux": true, "nacl": true, "netbsd": true, "openbsd": true, "plan9": true,
+	"solaris": true, "wasip1": true, "windows": true, "zos": true,
+}
+
+var goVersionSuffix = regexp.MustCompile(`\.go\d+\.\d+$`)
+
+var variantPattern = regexp.MustCompile(`^(.+?)(\.[a-z0-9]+)?(\.go\d+\.\d+)?$`)
+
+// baseVariant returns the path of the golden file without any operating
+// system or Go version variant in the filename.
+func baseVariant(path string) string {
+	dir, base := filepath.Split(path)
+	name, ext := splitExt(base)
+	// variants of files without an extension
+	if knownOS[strings.TrimPrefix(ext, ".")] || goVersionSuffix.MatchString(base) {
+		name, ext = base, ""
+	}
+	match := variantPattern.FindStringSubmatch(name)
+	if match == nil {
+		return path
+	}
+	name = match[1]
+	if osName := match[2]; osName != "" && !knownOS[osName[1:]] {
+		name += osName
+	}
+	return filepath.Join(dir, name+ext)
+}
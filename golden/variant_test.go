@@ -0,0 +1,86 @@
+package golden
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+)
+
+func TestResolveVariant(t *testing.T) {
+	goos := runtime.GOOS
+	var testcases = []struct {
+		name     string
+		files    []string
+		expected string
+	}{
+		{
+			name:     "no variants",
+			files:    []string{"out.golden"},
+			expected: "out.golden",
+		},
+		{
+			name:     "missing file",
+			expected: "out.golden",
+		},
+		{
+			name:     "os variant",
+			files:    []string{"out.golden", "out." + goos + ".golden", "out.other.golden"},
+			expected: "out." + goos + ".golden",
+		},
+		{
+			name:     "highest applicable version variant",
+			files:    []string{"out.golden", "out.go1.1.golden", "out.go1.12.golden", "out.go99.0.golden"},
+			expected: "out.go1.12.golden",
+		},
+		{
+			name:     "os variant before version variant",
+			files:    []string{"out.go1.12.golden", "out." + goos + ".golden"},
+			expected: "out." + goos + ".golden",
+		},
+		{
+			name:     "os and version variant",
+			files:    []string{"out." + goos + ".golden", "out." + goos + ".go1.12.golden"},
+			expected: "out." + goos + ".go1.12.golden",
+		},
+		{
+			name:     "no extension",
+			files:    []string{"out", "out." + goos},
+			expected: "out." + goos,
+		},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			var ops []fs.PathOp
+			for _, file := range testcase.files {
+				ops = append(ops, fs.WithFile(file, ""))
+			}
+			dir := fs.NewDir(t, t.Name(), ops...)
+			defer dir.Remove()
+
+			actual := resolveVariant(filepath.Join(dir.Path(), baseVariant(testcase.expected)))
+			assert.Equal(t, actual, dir.Join(testcase.expected))
+		})
+	}
+}
+
+func TestBaseVariant(t *testing.T) {
+	var testcases = []struct {
+		path     string
+		expected string
+	}{
+		{path: "out.golden", expected: "out.golden"},
+		{path: "out.windows.golden", expected: "out.golden"},
+		{path: "out.go1.21.golden", expected: "out.golden"},
+		{path: "out.linux.go1.21.golden", expected: "out.golden"},
+		{path: "out.golden.darwin.json", expected: "out.golden.json"},
+		{path: "out.other.golden", expected: "out.other.golden"},
+		{path: "dir/out.linux", expected: "dir/out"},
+		{path: "out.go1.12", expected: "out"},
+	}
+	for _, testcase := range testcases {
+		assert.Equal(t, baseVariant(testcase.path), filepath.FromSlash(testcase.expected))
+	}
+}
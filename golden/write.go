@@ -0,0 +1,93 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type namedT interface {
+	Name() string
+}
+
+// testName returns the name of the test if t implements Name, otherwise it
+// returns an empty string.
+func testName(t interface{}) string {
+	if nt, ok := t.(namedT); ok {
+		return nt.Name()
+	}
+	return ""
+}
+
+type pendingUpdate struct {
+	testName string
+	content  []byte
+}
+
+type updates struct {
+	mu     sync.Mutex
+	byPath map[string]pendingUpdate
+}
+
+var goldenUpdates = &updates{byPath: make(map[string]pendingUpdate)}
+
+// record the content written to the golden file at path by the test. Returns an
+// error if a different test has already written different content to the same
+// golden file. The result of the update would depend on the order in which
+// the tests run. Only writes from the same process are recorded, so a conflict
+// with a test in a different test binary is not detected.
+func (u *updates) record(path string, content []byte, testName string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	path = filepath.Clean(path)
+	previous, ok := u.byPath[path]
+	if ok && !bytes.Equal(previous.content, content) {
+		return fmt.Errorf("golden file %s was updated with different values by %s and %s",
+			path, describeTest(previous.testName), describeTest(testName))
+	}
+	u.byPath[path] = pendingUpdate{testName: testName, content: content}
+	return nil
+}
+
+func describeTest(name string) string {
+	if name == "" {
+		return "an unknown test"
+	}
+	return name
+}
+
+// writeFileAtomic writes content to a temporary file in the same directory as
+// path, and then renames the temporary file to path, so that the golden file
+// is never left partially written. The mode of an existing file is preserved,
+// a new file is created with mode 0644.
+func writeFileAtomic(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	switch info, err := os.Stat(path); {
+	case err == nil:
+		mode = info.Mode().Perm()
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	dir, base := filepath.Split(path)
+	tmp, err := ioutil.TempFile(filepath.Clean(dir+"."), "."+base+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close() // nolint: errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,61 @@
+package golden
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+)
+
+func TestUpdate_ConflictingValues(t *testing.T) {
+	undo := setUpdateFlag()
+	defer undo()
+	filename, clean := setupGoldenFile(t, "")
+	defer clean()
+
+	first := &namedFakeT{name: "TestFirst"}
+	Assert(first, "one", filename)
+	assert.Assert(t, !first.Failed)
+
+	same := &namedFakeT{name: "TestSame"}
+	Assert(same, "one", filename)
+	assert.Assert(t, !same.Failed)
+
+	second := &namedFakeT{name: "TestSecond"}
+	result := compareString("two", filename, &Settings{testName: second.Name()})()
+	assert.Assert(t, !result.Success())
+	expected := "golden file " + Path(filename) +
+		" was updated with different values by TestSame and TestSecond"
+	assert.Equal(t, result.(failure).FailureMessage(), expected)
+
+	undo()
+	assert.Equal(t, string(Get(t, filename)), "one")
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(), fs.WithFile("file.golden", "old content"))
+	defer dir.Remove()
+
+	err := writeFileAtomic(dir.Join("file.golden"), []byte("new content"))
+	assert.NilError(t, err)
+
+	expected := fs.Expected(t,
+		fs.WithFile("file.golden", "new content", fs.MatchAnyFileMode))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+}
+
+func TestWriteFileAtomic_FileMode(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("file.golden", "old content", fs.WithMode(0600)))
+	defer dir.Remove()
+
+	err := writeFileAtomic(dir.Join("file.golden"), []byte("new content"))
+	assert.NilError(t, err)
+	err = writeFileAtomic(dir.Join("new.golden"), []byte("new file"))
+	assert.NilError(t, err)
+
+	expected := fs.Expected(t,
+		fs.WithFile("file.golden", "new content", fs.WithMode(0600)),
+		fs.WithFile("new.golden", "new file", fs.WithMode(0644)))
+	assert.Assert(t, fs.Equal(dir.Path(), expected))
+}
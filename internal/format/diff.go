@@ -62,6 +62,25 @@ func UnifiedDiff(conf DiffConfig) string {
 	return buf.String()
 }
 
+// DiffStat returns the number of lines added and removed by the changes
+// required to turn a into b.
+func DiffStat(a, b string) (added int, removed int) {
+	linesA := strings.SplitAfter(a, "\n")
+	linesB := strings.SplitAfter(b, "\n")
+	for _, opCode := range difflib.NewMatcher(linesA, linesB).GetOpCodes() {
+		switch opCode.Tag {
+		case 'r':
+			removed += opCode.I2 - opCode.I1
+			added += opCode.J2 - opCode.J1
+		case 'd':
+			removed += opCode.I2 - opCode.I1
+		case 'i':
+			added += opCode.J2 - opCode.J1
+		}
+	}
+	return added, removed
+}
+
 // hasWhitespaceDiffLines returns true if any diff groups is only different
 // because of whitespace characters.
 func hasWhitespaceDiffLines(groups [][]difflib.OpCode, a, b []string) bool {
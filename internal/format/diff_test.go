@@ -69,3 +69,13 @@ func TestUnifiedDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestDiffStat(t *testing.T) {
+	added, removed := format.DiffStat("a\nb\nc\nd\n", "a\nB\nc\ne\nf\n")
+	assert.Equal(t, added, 3)
+	assert.Equal(t, removed, 2)
+
+	added, removed = format.DiffStat("same\n", "same\n")
+	assert.Equal(t, added, 0)
+	assert.Equal(t, removed, 0)
+}
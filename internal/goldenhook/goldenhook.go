@@ -0,0 +1,20 @@
+/*Package goldenhook connects packages which can not import the golden package,
+because they are imported by it, to the golden package.
+
+The hooks are set by the golden package when it is linked into the test binary,
+and are nil otherwise.
+*/
+package goldenhook
+
+import "gotest.tools/v3/assert/cmp"
+
+// Compare compares actual to the golden file filename the same way as
+// golden.Bytes. The golden file is recorded as used, and is updated or
+// reviewed as requested by the flags of the golden package. When the content
+// does not match, the result is nil and expected is the content of the golden
+// file.
+var Compare func(actual []byte, filename string) (result cmp.Result, expected []byte)
+
+// Path returns the path of the golden file filename, including any variant
+// selected by golden.Path.
+var Path func(filename string) string
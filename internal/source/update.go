@@ -0,0 +1,196 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// UpdateExpectedValue replaces the string literal argument at argPos, of the
+// ast.CallExpr at the index in the call stack, with a literal of value. The
+// source file is formatted with go/format after it is modified.
+//
+// Line numbers from the call stack refer to the source file as it was
+// compiled. UpdateExpectedValue keeps track of previous updates to the file so
+// that multiple calls from the same file can be updated in the same run.
+func UpdateExpectedValue(stackIndex, argPos int, value string) error {
+	site, err := Caller(stackIndex + 1)
+	if err != nil {
+		return err
+	}
+	_, err = UpdateExpectedValueAt(site, argPos, value)
+	return err
+}
+
+// CallSite is the position of a call in a source file, as it was compiled.
+type CallSite struct {
+	Filename string
+	Line     int
+}
+
+func (s CallSite) String() string {
+	return fmt.Sprintf("%s:%d", s.Filename, s.Line)
+}
+
+// Caller returns the position of the call at the index in the call stack.
+func Caller(stackIndex int) (CallSite, error) {
+	_, filename, lineNum, ok := runtime.Caller(baseStackIndex + stackIndex)
+	if !ok {
+		return CallSite{}, errors.New("failed to get call stack")
+	}
+	debug("call stack position: %s:%d", filename, lineNum)
+	return CallSite{Filename: filename, Line: lineNum}, nil
+}
+
+// UpdateExpectedValueAt is like UpdateExpectedValue, for the call at site. It
+// returns the source of the literal which was replaced, so that the update can
+// be reverted with RestoreLiteral.
+func UpdateExpectedValueAt(site CallSite, argPos int, value string) (string, error) {
+	return replaceAt(site, argPos, func(original string) string {
+		return stringLiteral(original, value)
+	})
+}
+
+// RestoreLiteral replaces the string literal argument at argPos, of the call at
+// site, with literal, the source of a literal returned by
+// UpdateExpectedValueAt.
+func RestoreLiteral(site CallSite, argPos int, literal string) error {
+	_, err := replaceAt(site, argPos, func(string) string {
+		return literal
+	})
+	return err
+}
+
+func replaceAt(site CallSite, argPos int, newLiteral func(original string) string) (string, error) {
+	fileUpdates.Lock()
+	defer fileUpdates.Unlock()
+	current := fileUpdates.currentLine(site.Filename, site.Line)
+	delta, original, err := replaceStringLiteral(site.Filename, current, argPos, newLiteral)
+	if err != nil {
+		return "", err
+	}
+	fileUpdates.add(site.Filename, site.Line, delta)
+	return original, nil
+}
+
+type lineShift struct {
+	line  int
+	delta int
+}
+
+type updatedFiles struct {
+	sync.Mutex
+	shifts map[string][]lineShift
+}
+
+var fileUpdates = &updatedFiles{shifts: make(map[string][]lineShift)}
+
+func (u *updatedFiles) currentLine(filename string, lineNum int) int {
+	current := lineNum
+	for _, shift := range u.shifts[filename] {
+		if shift.line < lineNum {
+			current += shift.delta
+		}
+	}
+	return current
+}
+
+func (u *updatedFiles) add(filename string, lineNum int, delta int) {
+	if delta != 0 {
+		u.shifts[filename] = append(u.shifts[filename], lineShift{line: lineNum, delta: delta})
+	}
+}
+
+// ReplaceStringLiteralArg replaces the string literal argument at argPos, of the
+// ast.CallExpr at lineNum in filename, with a literal of value. Raw string
+// literals are preserved if value can be represented as a raw string.
+//
+// Returns the number of lines added to the file, which may be negative if lines
+// were removed.
+func ReplaceStringLiteralArg(filename string, lineNum int, argPos int, value string) (int, error) {
+	delta, _, err := replaceStringLiteral(filename, lineNum, argPos, func(original string) string {
+		return stringLiteral(original, value)
+	})
+	return delta, err
+}
+
+// replaceStringLiteral replaces the string literal argument at argPos, of the
+// ast.CallExpr at lineNum in filename, with the literal returned by newLiteral.
+// Returns the number of lines added to the file, and the source of the literal
+// which was replaced.
+func replaceStringLiteral(
+	filename string,
+	lineNum int,
+	argPos int,
+	newLiteral func(original string) string,
+) (int, string, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	fileset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fileset, filename, src, parser.AllErrors)
+	if err != nil {
+		return 0, "", errors.Wrapf(err, "failed to parse source file: %s", filename)
+	}
+
+	node := scanToLine(fileset, astFile, lineNum)
+	if node == nil {
+		return 0, "", errors.Errorf(
+			"failed to find an expression on line %d in %s", lineNum, filename)
+	}
+	args, err := getCallExprArgs(node)
+	if err != nil {
+		return 0, "", err
+	}
+	if argPos >= len(args) {
+		return 0, "", errors.New("failed to find expression")
+	}
+	lit, ok := args[argPos].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		formatted, _ := FormatNode(args[argPos])
+		return 0, "", errors.Errorf("expected value must be a string literal, not %s", formatted)
+	}
+
+	newLit := newLiteral(lit.Value)
+	start := fileset.Position(lit.Pos()).Offset
+	end := fileset.Position(lit.End()).Offset
+	updated := append(append(append([]byte{}, src[:start]...), newLit...), src[end:]...)
+	formatted, err := format.Source(updated)
+	if err != nil {
+		return 0, "", errors.Wrapf(err, "failed to format source file: %s", filename)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := ioutil.WriteFile(filename, formatted, info.Mode()); err != nil {
+		return 0, "", err
+	}
+	return strings.Count(newLit, "\n") - strings.Count(lit.Value, "\n"), lit.Value, nil
+}
+
+// stringLiteral returns a string literal for value using the same style as the
+// original literal.
+func stringLiteral(original string, value string) string {
+	if strings.HasPrefix(original, "`") && canBeRawString(value) {
+		return "`" + value + "`"
+	}
+	return strconv.Quote(value)
+}
+
+func canBeRawString(value string) bool {
+	return !strings.ContainsAny(value, "`\r") &&
+		strconv.CanBackquote(strings.Replace(value, "\n", "", -1))
+}
@@ -0,0 +1,67 @@
+package source_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+	"gotest.tools/v3/internal/source"
+)
+
+const sourceToUpdate = `package example
+
+func TestExample(t *testing.T) {
+	inline(t, "actual", "old value")
+	inline(t, "actual",
+		` + "`" + `old
+raw value` + "`" + `)
+	inline(t, "actual", notALiteral)
+}
+`
+
+func TestReplaceStringLiteralArg(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(), fs.WithFile("example_test.go", sourceToUpdate))
+	defer dir.Remove()
+	filename := dir.Join("example_test.go")
+
+	delta, err := source.ReplaceStringLiteralArg(filename, 4, 2, "new\t\"value\"")
+	assert.NilError(t, err)
+	assert.Equal(t, delta, 0)
+
+	delta, err = source.ReplaceStringLiteralArg(filename, 5, 2, "new\nraw\nvalue")
+	assert.NilError(t, err)
+	assert.Equal(t, delta, 1)
+
+	_, err = source.ReplaceStringLiteralArg(filename, 9, 2, "value")
+	assert.Error(t, err, "expected value must be a string literal, not notALiteral")
+
+	expected := `package example
+
+func TestExample(t *testing.T) {
+	inline(t, "actual", "new\t\"value\"")
+	inline(t, "actual",
+		` + "`" + `new
+raw
+value` + "`" + `)
+	inline(t, "actual", notALiteral)
+}
+`
+	raw, err := ioutil.ReadFile(filename)
+	assert.NilError(t, err)
+	assert.Equal(t, string(raw), expected)
+}
+
+func TestReplaceStringLiteralArg_RawStringWithBackquote(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithFile("example_test.go", "package example\n\nvar _ = inline(t, `x`, `old`)\n"))
+	defer dir.Remove()
+	filename := dir.Join("example_test.go")
+
+	_, err := source.ReplaceStringLiteralArg(filename, 3, 2, "has ` quote")
+	assert.NilError(t, err)
+
+	raw, err := ioutil.ReadFile(filename)
+	assert.NilError(t, err)
+	assert.Equal(t, string(raw), "package example\n\nvar _ = inline(t, `x`, \"has ` quote\")\n")
+}
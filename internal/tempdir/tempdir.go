@@ -0,0 +1,8 @@
+/*Package tempdir defines the env var used to set the directory where the fs
+package creates temporary files, so that other packages can find them.
+*/
+package tempdir
+
+// EnvTempDir is the name of the env var used to set the directory where
+// fs.NewFile and fs.NewDir create temporary files.
+const EnvTempDir = "GOTESTTOOLS_FS_TEMPDIR"
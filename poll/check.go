@@ -1,8 +1,13 @@
 package poll
 
 import (
+	"bytes"
+	"io/ioutil"
 	"net"
 	"os"
+	"time"
+
+	"gotest.tools/v3/fs"
 )
 
 // Check is a function which will be used as check for the WaitOn method.
@@ -37,3 +42,88 @@ func Connection(network, address string) Check {
 		return Success()
 	}
 }
+
+// FileContains looks on filesystem and checks that the file at path exists,
+// and contains substr.
+func FileContains(path, substr string) Check {
+	return func(t LogT) Result {
+		content, err := ioutil.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			t.Logf("waiting on file %s to exist", path)
+			return Continue("file %s does not exist", path)
+		case err != nil:
+			return Error(err)
+		case !bytes.Contains(content, []byte(substr)):
+			t.Logf("waiting on file %s to contain %q", path, substr)
+			return Continue("file %s does not contain %q", path, substr)
+		}
+		return Success()
+	}
+}
+
+// FileMatches looks on filesystem and checks that the file at path exists,
+// and matches the expectations defined by ops. Only the properties set by the
+// ops are compared. See fs.EqualFile.
+func FileMatches(path string, ops ...fs.PathOp) Check {
+	return func(t LogT) Result {
+		_, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			t.Logf("waiting on file %s to exist", path)
+			return Continue("file %s does not exist", path)
+		case err != nil:
+			return Error(err)
+		}
+		return Compare(fs.EqualFile(path, ops...))
+	}
+}
+
+// DirMatches looks on filesystem and checks that the directory at path
+// matches the expected Manifest, using fs.Equal.
+func DirMatches(path string, expected fs.Manifest) Check {
+	return func(t LogT) Result {
+		_, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			t.Logf("waiting on directory %s to exist", path)
+			return Continue("directory %s does not exist", path)
+		case err != nil:
+			return Error(err)
+		}
+		return Compare(fs.Equal(path, expected))
+	}
+}
+
+// FileStable looks on filesystem and checks that the size and modification
+// time of the file at path have not changed for the quiet duration. Use it to
+// wait for another process to finish writing a file.
+//
+// The file is checked every time the Check is called, so the delay set with
+// WithDelay should be shorter than quiet. A Check returned by FileStable should
+// only be used with a single call to WaitOn.
+func FileStable(path string, quiet time.Duration) Check {
+	var last os.FileInfo
+	var changed time.Time
+	return func(t LogT) Result {
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			last = nil
+			t.Logf("waiting on file %s to exist", path)
+			return Continue("file %s does not exist", path)
+		case err != nil:
+			return Error(err)
+		}
+
+		now := time.Now()
+		if last == nil || info.Size() != last.Size() || !info.ModTime().Equal(last.ModTime()) {
+			last, changed = info, now
+		}
+		if unchanged := now.Sub(changed); unchanged < quiet {
+			t.Logf("waiting on file %s to stop changing", path)
+			return Continue("file %s has not changed for %s, waiting for %s", path, unchanged, quiet)
+		}
+		return Success()
+	}
+}
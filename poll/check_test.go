@@ -2,10 +2,14 @@ package poll
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/fs"
 )
 
 func TestWaitOnFile(t *testing.T) {
@@ -40,3 +44,76 @@ func TestWaitOnSocketWithTimeout(t *testing.T) {
 		assert.Assert(t, check(t).Done())
 	})
 }
+
+func TestFileContains(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	defer dir.Remove()
+	check := FileContains(dir.Join("log"), "started")
+
+	r := check(t)
+	assert.Assert(t, !r.Done())
+	assert.Equal(t, r.Message(), fmt.Sprintf("file %s does not exist", dir.Join("log")))
+
+	assert.NilError(t, ioutil.WriteFile(dir.Join("log"), []byte("starting\n"), 0644))
+	r = check(t)
+	assert.Assert(t, !r.Done())
+	assert.Equal(t, r.Message(), fmt.Sprintf(`file %s does not contain "started"`, dir.Join("log")))
+
+	assert.NilError(t, ioutil.WriteFile(dir.Join("log"), []byte("starting\nstarted\n"), 0644))
+	assert.Assert(t, check(t).Done())
+}
+
+func TestFileMatches(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	defer dir.Remove()
+	check := FileMatches(dir.Join("config.json"), fs.ContentJSONEqual(`{"ready": true}`))
+
+	r := check(t)
+	assert.Assert(t, !r.Done())
+	assert.Equal(t, r.Message(), fmt.Sprintf("file %s does not exist", dir.Join("config.json")))
+
+	assert.NilError(t, ioutil.WriteFile(dir.Join("config.json"), []byte(`{"ready": false}`), 0644))
+	r = check(t)
+	assert.Assert(t, !r.Done())
+	assert.Assert(t, is.Contains(r.Message(), "content: JSON is not equal"))
+
+	assert.NilError(t, ioutil.WriteFile(dir.Join("config.json"), []byte(`{"ready": true}`), 0644))
+	assert.Assert(t, check(t).Done())
+}
+
+func TestDirMatches(t *testing.T) {
+	dir := fs.NewDir(t, t.Name())
+	defer dir.Remove()
+	expected := fs.Expected(t,
+		fs.MatchAnyFileMode,
+		fs.WithFile("file1", "content", fs.MatchAnyFileMode))
+	check := DirMatches(dir.Join("out"), expected)
+
+	r := check(t)
+	assert.Assert(t, !r.Done())
+	assert.Equal(t, r.Message(), fmt.Sprintf("directory %s does not exist", dir.Join("out")))
+
+	fs.Apply(t, dir, fs.WithDir("out"))
+	r = check(t)
+	assert.Assert(t, !r.Done())
+	assert.Assert(t, is.Contains(r.Message(), "- file1"))
+
+	assert.NilError(t, ioutil.WriteFile(dir.Join("out", "file1"), []byte("content"), 0644))
+	assert.Assert(t, check(t).Done())
+	// the expected content is not consumed by the first comparison
+	assert.Assert(t, check(t).Done())
+}
+
+func TestFileStable(t *testing.T) {
+	file := fs.NewFile(t, t.Name())
+	defer file.Remove()
+	check := FileStable(file.Path(), 50*time.Millisecond)
+
+	assert.Assert(t, !check(t).Done())
+	assert.NilError(t, ioutil.WriteFile(file.Path(), []byte("more content"), 0644))
+	r := check(t)
+	assert.Assert(t, !r.Done())
+	assert.Assert(t, is.Contains(r.Message(), "waiting for 50ms"))
+
+	WaitOn(t, check, WithDelay(10*time.Millisecond), WithTimeout(time.Second))
+}